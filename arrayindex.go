@@ -0,0 +1,74 @@
+package gojsondiff
+
+import "sort"
+
+// An arrayIndexMap maps the indexes of the items of an array which are kept by
+// the deltas of the array between the left side (pre) and the right side (post).
+// Kept items are neither deleted, added nor moved, so they keep their order.
+type arrayIndexMap struct {
+	// removed holds the sorted pre indexes of the Deleted and Moved deltas
+	removed []int
+	// inserted holds the sorted post indexes of the Added and Moved deltas
+	inserted []int
+}
+
+func newArrayIndexMap(deltas []Delta) *arrayIndexMap {
+	m := &arrayIndexMap{}
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Deleted:
+			m.removed = append(m.removed, int(d.PrePosition().(Index)))
+		case *Added:
+			m.inserted = append(m.inserted, int(d.PostPosition().(Index)))
+		case *Moved:
+			m.removed = append(m.removed, int(d.PrePosition().(Index)))
+			m.inserted = append(m.inserted, int(d.PostPosition().(Index)))
+		}
+	}
+	sort.Ints(m.removed)
+	sort.Ints(m.inserted)
+	return m
+}
+
+// preIndex returns the left side index of the kept item at the post index.
+func (m *arrayIndexMap) preIndex(post int) int {
+	return nthIndexNotIn(m.removed, post-countBelow(m.inserted, post))
+}
+
+// postIndex returns the right side index of the kept item at the pre index.
+func (m *arrayIndexMap) postIndex(pre int) int {
+	return nthIndexNotIn(m.inserted, pre-countBelow(m.removed, pre))
+}
+
+// countBelow returns the number of indexes lower than index.
+func countBelow(sortedIndexes []int, index int) int {
+	return sort.SearchInts(sortedIndexes, index)
+}
+
+// nthIndexNotIn returns the nth (from 0) index which is not one of the indexes.
+func nthIndexNotIn(sortedIndexes []int, n int) int {
+	index := n
+	for _, excluded := range sortedIndexes {
+		if excluded > index {
+			break
+		}
+		index++
+	}
+	return index
+}
+
+// isArrayDeltas returns true if the deltas are the changes of an array,
+// i.e. their positions are Indexes.
+func isArrayDeltas(deltas []Delta) bool {
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case PostDelta:
+			_, ok := d.PostPosition().(Index)
+			return ok
+		case PreDelta:
+			_, ok := d.PrePosition().(Index)
+			return ok
+		}
+	}
+	return false
+}
@@ -0,0 +1,268 @@
+package gojsondiff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// Names of the budgets reported by BudgetError.
+const (
+	BudgetLcsCells        = "MaxLcsCells"
+	BudgetSimilarityCells = "MaxSimilarityCells"
+	BudgetDepth           = "MaxDepth"
+)
+
+// A BudgetError is returned by a Differ with the FailOnBudget option
+// when a comparison exceeds one of its budgets.
+type BudgetError struct {
+	// Budget is the name of the exceeded budget, e.g. BudgetLcsCells.
+	Budget string
+	// Path is the JSON Pointer of the value whose comparison exceeded the budget.
+	Path string
+	// Limit is the configured budget and Required the amount the comparison needed.
+	Limit    int
+	Required int
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("comparison of `%s` exceeds %s: %d > %d", e.Path, e.Budget, e.Required, e.Limit)
+}
+
+// MaxLcsCells limits the size of the LCS table built to compare two arrays,
+// i.e. the product of their lengths once their common prefix and suffix are
+// trimmed. Larger arrays are compared position by position.
+func MaxLcsCells(cells int) Option {
+	return func(differ *Differ) {
+		differ.maxLcsCells = cells
+	}
+}
+
+// MaxSimilarityCells limits the number of pairs of items compared to find
+// modified items between the unmatched items of two arrays. When there are
+// more pairs, the unmatched items are reported as deleted and added.
+func MaxSimilarityCells(cells int) Option {
+	return func(differ *Differ) {
+		differ.maxSimilarityCells = cells
+	}
+}
+
+// MaxDepth limits how deep the Differ descends into documents. Objects and
+// arrays nested deeper than depth, where the members of the root have depth 1,
+// are compared as a whole and replaced by a Modified delta when they differ.
+func MaxDepth(depth int) Option {
+	return func(differ *Differ) {
+		differ.maxDepth = depth
+	}
+}
+
+// FailOnBudget makes Compare and the Context methods return a *BudgetError
+// when a budget is exceeded, instead of degrading to a cheaper comparison.
+// The other methods cannot report errors and always degrade.
+func FailOnBudget() Option {
+	return func(differ *Differ) {
+		differ.failOnBudget = true
+	}
+}
+
+// CompareContext is like Compare, but stops when the context is done
+// and returns the error of the context.
+func (differ *Differ) CompareContext(
+	ctx context.Context,
+	left []byte,
+	right []byte,
+) (Diff, error) {
+	var leftValue, rightValue interface{}
+	err := differ.unmarshal(left, &leftValue)
+	if err != nil {
+		return nil, err
+	}
+
+	err = differ.unmarshal(right, &rightValue)
+	if err != nil {
+		return nil, err
+	}
+	return differ.CompareValuesContext(ctx, leftValue, rightValue)
+}
+
+// CompareValuesContext is like CompareValues, but stops when the context is
+// done and reports exceeded budgets with the FailOnBudget option.
+func (differ *Differ) CompareValuesContext(
+	ctx context.Context,
+	left interface{},
+	right interface{},
+) (Diff, error) {
+	c := differ.newComparison(ctx, true)
+	deltas := c.compareRoots(left, right)
+	return c.result(deltas)
+}
+
+// CompareObjectsContext is like CompareObjects, but stops when the context is
+// done and reports exceeded budgets with the FailOnBudget option.
+func (differ *Differ) CompareObjectsContext(
+	ctx context.Context,
+	left map[string]interface{},
+	right map[string]interface{},
+) (Diff, error) {
+	c := differ.newComparison(ctx, true)
+	deltas := c.compareRoots(left, right)
+	return c.result(deltas)
+}
+
+// CompareArraysContext is like CompareArrays, but stops when the context is
+// done and reports exceeded budgets with the FailOnBudget option.
+func (differ *Differ) CompareArraysContext(
+	ctx context.Context,
+	left []interface{},
+	right []interface{},
+) (Diff, error) {
+	c := differ.newComparison(ctx, true)
+	deltas := c.compareRoots(left, right)
+	return c.result(deltas)
+}
+
+// result returns the Diff of the comparison or the error which stopped it.
+func (c *comparison) result(deltas []Delta) (Diff, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	return c.newDiff(deltas), nil
+}
+
+// stopped returns true once the context of the comparison is done or a budget
+// made it fail. The comparison then returns as soon as possible.
+func (c *comparison) stopped() bool {
+	if c.err == nil {
+		c.err = c.ctx.Err()
+	}
+	return c.err != nil
+}
+
+// exceeded returns true if the comparison should degrade because the budget
+// is exceeded. The comparison fails instead if it can report the error.
+func (c *comparison) exceeded(budget string, path Path, limit int, required int) bool {
+	if limit <= 0 || required <= limit {
+		return false
+	}
+	if c.failOnBudget && c.canFail && c.err == nil {
+		c.err = &BudgetError{Budget: budget, Path: path.String(), Limit: limit, Required: required}
+	}
+	return true
+}
+
+// tooDeep returns true if the containers at the path must be compared as a whole.
+func (c *comparison) tooDeep(path Path) bool {
+	return c.exceeded(BudgetDepth, path, c.maxDepth, len(path))
+}
+
+// compareWhole compares two containers without descending into them.
+func (c *comparison) compareWhole(path Path, left interface{}, right interface{}) (same bool, delta Delta) {
+	if reflect.DeepEqual(c.withoutIgnored(path, c.normalizeDeep(path, left)), c.withoutIgnored(path, c.normalizeDeep(path, right))) {
+		return true, nil
+	}
+	return false, NewModified(path.position(), left, right)
+}
+
+// trimCommon returns the length of the common prefix and suffix of two arrays.
+func trimCommon(left []interface{}, right []interface{}) (prefix int, suffix int) {
+	for prefix < len(left) && prefix < len(right) && reflect.DeepEqual(left[prefix], right[prefix]) {
+		prefix++
+	}
+	for suffix < len(left)-prefix && suffix < len(right)-prefix &&
+		reflect.DeepEqual(left[len(left)-1-suffix], right[len(right)-1-suffix]) {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+// lcs returns the Lcs of two arrays made by the LcsAlgorithm of the Differ,
+// NewLCS for a zero Differ.
+func (differ *Differ) lcs(left, right []interface{}) Lcs {
+	if differ.newLcs == nil {
+		return NewLCS(left, right)
+	}
+	return differ.newLcs(left, right)
+}
+
+// budgetedLcsPairs returns the LCS index pairs of the tokens of two arrays
+// within the MaxLcsCells budget. Only the items between the common prefix and
+// suffix are given to the LCS when the whole arrays exceed the budget.
+// positional is true when even those exceed it, the pairs are then the common
+// prefix and suffix only and the items between them are compared by position.
+func (c *comparison) budgetedLcsPairs(path Path, leftTokens []interface{}, rightTokens []interface{}) (pairs []IndexPair, positional bool) {
+	if c.maxLcsCells <= 0 || len(leftTokens)*len(rightTokens) <= c.maxLcsCells {
+		pairs, err := c.lcs(leftTokens, rightTokens).IndexPairsContext(c.ctx)
+		if err != nil {
+			c.err = err
+		}
+		return pairs, false
+	}
+
+	prefix, suffix := trimCommon(leftTokens, rightTokens)
+	leftMiddle := leftTokens[prefix : len(leftTokens)-suffix]
+	rightMiddle := rightTokens[prefix : len(rightTokens)-suffix]
+
+	pairs = make([]IndexPair, 0, prefix+suffix)
+	for i := 0; i < prefix; i++ {
+		pairs = append(pairs, IndexPair{Left: i, Right: i})
+	}
+	positional = c.exceeded(BudgetLcsCells, path, c.maxLcsCells, len(leftMiddle)*len(rightMiddle))
+	if !positional {
+		middlePairs, err := c.lcs(leftMiddle, rightMiddle).IndexPairsContext(c.ctx)
+		if err != nil {
+			c.err = err
+		}
+		for _, pair := range middlePairs {
+			pairs = append(pairs, IndexPair{Left: prefix + pair.Left, Right: prefix + pair.Right})
+		}
+	}
+	for i := 0; i < suffix; i++ {
+		pairs = append(pairs, IndexPair{
+			Left:  len(leftTokens) - suffix + i,
+			Right: len(rightTokens) - suffix + i,
+		})
+	}
+	return pairs, positional
+}
+
+// comparePositionally compares the items of two arrays which are not in the
+// pairs by their order, in linear time. The unpaired items must be contiguous,
+// as they are between the common prefix and suffix of the arrays.
+func (c *comparison) comparePositionally(
+	path Path,
+	left []interface{},
+	right []interface{},
+	pairs []IndexPair,
+) (deltas []Delta) {
+	leftIndexes := unpairedIndexes(len(left), pairs, func(pair IndexPair) int { return pair.Left })
+	rightIndexes := unpairedIndexes(len(right), pairs, func(pair IndexPair) int { return pair.Right })
+
+	k := 0
+	for ; k < len(leftIndexes) && k < len(rightIndexes); k++ {
+		i, j := leftIndexes[k], rightIndexes[k]
+		if same, delta := c.compareValues(path.child(Index(j)), left[i], right[j]); !same {
+			deltas = append(deltas, delta)
+		}
+	}
+	for _, i := range leftIndexes[k:] {
+		deltas = append(deltas, NewDeleted(Index(i), c.recorded(path.child(Index(i)), left[i])))
+	}
+	for _, j := range rightIndexes[k:] {
+		deltas = append(deltas, NewAdded(Index(j), c.recorded(path.child(Index(j)), right[j])))
+	}
+	return deltas
+}
+
+// unpairedIndexes returns the indexes of an array of the given length which
+// are not in the pairs.
+func unpairedIndexes(length int, pairs []IndexPair, side func(pair IndexPair) int) (indexes []int) {
+	p := 0
+	for i := 0; i < length; i++ {
+		if p < len(pairs) && side(pairs[p]) == i {
+			p++
+			continue
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// stdinName is the file name of the standard input.
+const stdinName = "-"
+
+// An invalidJsonError is returned for documents which are not valid JSON.
+type invalidJsonError struct {
+	name string
+	err  error
+}
+
+func (e *invalidJsonError) Error() string {
+	return fmt.Sprintf("Failed to unmarshal file '%s': %s", e.name, e.err)
+}
+
+// exitCode returns the exit code for an error reading the documents.
+func exitCode(err error) int {
+	var invalid *invalidJsonError
+	if errors.As(err, &invalid) {
+		return exitInvalidJson
+	}
+	return exitError
+}
+
+// readDocuments reads the left and the right documents, one of which may be
+// read from stdin.
+func readDocuments(leftName string, rightName string, stdin io.Reader) (left interface{}, right interface{}, err error) {
+	if leftName == stdinName && rightName == stdinName {
+		return nil, nil, errors.New("Only one document can be read from the standard input")
+	}
+	if left, err = readDocument(leftName, stdin); err != nil {
+		return nil, nil, err
+	}
+	if right, err = readDocument(rightName, stdin); err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+// readDocument reads the JSON document of a file, or of stdin for "-".
+func readDocument(name string, stdin io.Reader) (interface{}, error) {
+	var content []byte
+	var err error
+	if name == stdinName {
+		content, err = io.ReadAll(stdin)
+	} else {
+		content, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open file '%s': %s", name, err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		return nil, &invalidJsonError{name: name, err: err}
+	}
+	return document, nil
+}
+
+// writeOutput writes the result to the file, or to stdout when name is empty.
+func writeOutput(name string, stdout io.Writer, result string) error {
+	if name == "" {
+		_, err := io.WriteString(stdout, result)
+		return err
+	}
+	return os.WriteFile(name, []byte(result), 0644)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ = Describe("Jd", func() {
+	var (
+		dir            string
+		left, right    string
+		stdout, stderr *bytes.Buffer
+		writeFile      func(name, content string) string
+		jd             func(stdin string, args ...string) int
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "jd")
+		Expect(err).To(BeNil())
+		writeFile = func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+			return path
+		}
+		left = writeFile("left.json", `{"name": "app", "price": 1.0, "items": [{"id": 1, "v": "a"}, {"id": 2, "v": "b"}], "updatedAt": "monday"}`)
+		right = writeFile("right.json", `{"name": "service", "price": 1.001, "items": [{"id": 2, "v": "b"}, {"id": 1, "v": "a"}], "updatedAt": "tuesday"}`)
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		jd = func(stdin string, args ...string) int {
+			return run(args, strings.NewReader(stdin), stdout, stderr)
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Prints the diff in the ASCII format", func() {
+		Expect(jd("", left, right)).To(Equal(1))
+		Expect(stdout.String()).To(ContainSubstring(`-  "name": "app",`))
+		Expect(stdout.String()).To(ContainSubstring(`+  "name": "service",`))
+		Expect(stdout.String()).NotTo(ContainSubstring("\x1b["))
+
+		stdout.Reset()
+		Expect(jd("", "-c", left, right)).To(Equal(1))
+		Expect(stdout.String()).To(ContainSubstring("\x1b["))
+	})
+
+	It("Prints the diff in the other formats", func() {
+		Expect(jd("", "-f", "delta", left, right)).To(Equal(1))
+		var delta map[string]interface{}
+		Expect(json.Unmarshal(stdout.Bytes(), &delta)).To(Succeed())
+		Expect(delta["name"]).To(Equal([]interface{}{"app", "service"}))
+
+		stdout.Reset()
+		Expect(jd("", "-f", "jsonpatch", left, right)).To(Equal(1))
+		Expect(stdout.String()).To(ContainSubstring(`"op": "replace"`))
+
+		stdout.Reset()
+		Expect(jd("", "-f", "mergepatch", left, right)).To(Equal(1))
+		var patch map[string]interface{}
+		Expect(json.Unmarshal(stdout.Bytes(), &patch)).To(Succeed())
+		Expect(patch["updatedAt"]).To(Equal("tuesday"))
+	})
+
+	It("Sets the Differ options", func() {
+		Expect(jd("", "-f", "delta", "-ignore", "/updatedAt", "-ignore", "/name", "-keys", "/items=id", "-tolerance", "0.01", left, right)).To(Equal(1))
+		var delta map[string]interface{}
+		Expect(json.Unmarshal(stdout.Bytes(), &delta)).To(Succeed())
+		Expect(delta).To(Equal(map[string]interface{}{
+			"items": map[string]interface{}{"_t": "a", "_1": []interface{}{"", 0.0, 3.0}},
+		}))
+
+		stdout.Reset()
+		Expect(jd("", "-ignore", "/updatedAt", "-ignore", "/name", "-unordered", "/items", "-relative-tolerance", "0.01", left, right)).To(Equal(0))
+	})
+
+	It("Reads a document from the standard input", func() {
+		content, err := os.ReadFile(left)
+		Expect(err).To(BeNil())
+		Expect(jd(string(content), "-", left)).To(Equal(0))
+		Expect(jd(string(content), "-q", left, "-")).To(Equal(0))
+		Expect(stdout.String()).To(HavePrefix(" {"))
+	})
+
+	It("Prints nothing for the same documents in quiet mode", func() {
+		Expect(jd("", "-q", left, left)).To(Equal(0))
+		Expect(stdout.Len()).To(Equal(0))
+		Expect(jd("", "-q", left, right)).To(Equal(1))
+		Expect(stdout.Len()).NotTo(Equal(0))
+	})
+
+	It("Accepts the long names of the flags", func() {
+		Expect(jd("", "--quiet", left, left)).To(Equal(0))
+		Expect(stdout.Len()).To(Equal(0))
+
+		Expect(jd("", "--format", "delta", left, right)).To(Equal(1))
+		var delta map[string]interface{}
+		Expect(json.Unmarshal(stdout.Bytes(), &delta)).To(Succeed())
+
+		stdout.Reset()
+		Expect(jd("", "--coloring", left, right)).To(Equal(1))
+		Expect(stdout.String()).To(ContainSubstring("\x1b["))
+	})
+
+	It("Exits with the codes of the errors", func() {
+		Expect(jd("", left)).To(Equal(2))
+		Expect(jd("", "-keys", "items", left, right)).To(Equal(2))
+		Expect(jd("", "-ignore", "/a~2", left, right)).To(Equal(2))
+		Expect(jd("", "-unordered", "/a~", left, right)).To(Equal(2))
+		Expect(jd("", "-keys", "/items~3=id", left, right)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("invalid JSON Pointer `/a~2`: '~' must be followed by '0' or '1'"))
+		Expect(jd("", "-q", "-ignore", "/a~0b~1c", left, right)).To(Equal(1))
+		Expect(jd("", left, filepath.Join(dir, "missing.json"))).To(Equal(2))
+		Expect(jd("", "-", "-")).To(Equal(2))
+		Expect(jd("", left, writeFile("invalid.json", "{"))).To(Equal(3))
+		Expect(jd("", "-f", "yaml", left, right)).To(Equal(4))
+		Expect(stderr.String()).To(ContainSubstring("Unknown format `yaml`"))
+	})
+
+	It("Prints the version", func() {
+		Expect(jd("", "-version")).To(Equal(0))
+		Expect(stdout.String()).To(Equal("jd dev\n"))
+	})
+})
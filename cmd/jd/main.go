@@ -0,0 +1,123 @@
+// Command jd compares JSON documents.
+//
+// Usage:
+//
+//	jd [options] left.json right.json
+//	jd review [options] left.json right.json
+//
+// Either file may be "-" to read the standard input. jd exits with 0 when
+// the documents are the same, 1 when they differ and 2 or more on errors.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	diff "github.com/mrutkows/go-jsondiff"
+	"github.com/mrutkows/go-jsondiff/formatter"
+)
+
+// Version is set at link time, e.g. -ldflags "-X main.Version=v1.0.0".
+var Version = "dev"
+
+const (
+	exitSame        = 0
+	exitDifferent   = 1
+	exitError       = 2 // invalid arguments and unreadable files
+	exitInvalidJson = 3
+	exitFormat      = 4
+)
+
+const usage = `Usage: jd [options] left.json right.json
+       jd review [options] left.json right.json
+
+Either file may be "-" to read the standard input. The exit code is 0 when
+the documents are the same, 1 when they differ, 2 for invalid arguments and
+unreadable files, 3 for invalid JSON documents and 4 for formatting errors.
+
+Commands:
+  review  walk through the changes and write the document with the accepted
+          changes, or their delta (see jd review -h)
+
+Options:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs jd with the arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "review" {
+		return review(args[1:], stdin, stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet("jd", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("f", envString("DIFF_FORMAT", "ascii"), "output `format`: ascii, delta, jsonpatch or mergepatch (env DIFF_FORMAT)")
+	coloring := flags.Bool("c", envBool("COLORING"), "color the ascii output (env COLORING)")
+	quiet := flags.Bool("q", envBool("QUIET"), "print nothing when the documents are the same (env QUIET)")
+	version := flags.Bool("version", false, "print the version and exit")
+	// the long names of the flags of the former jd command
+	flags.StringVar(format, "format", *format, "same as -f")
+	flags.BoolVar(coloring, "coloring", *coloring, "same as -c")
+	flags.BoolVar(quiet, "quiet", *quiet, "same as -q")
+	options := newDifferFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if *version {
+		fmt.Fprintf(stdout, "jd %s\n", Version)
+		return exitSame
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	left, right, err := readDocuments(flags.Arg(0), flags.Arg(1), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+	d := diff.New(options.options()...).CompareValues(left, right)
+	if !d.Modified() && *quiet {
+		return exitSame
+	}
+
+	var output string
+	switch *format {
+	case "ascii":
+		config := formatter.AsciiFormatterConfig{ShowArrayIndex: true, Coloring: *coloring}
+		output, err = formatter.NewAsciiFormatter(left, config).Format(d)
+	case "delta":
+		output, err = formatter.NewDeltaFormatter().Format(d)
+	case "jsonpatch":
+		output, err = formatter.NewJsonPatchFormatter().Format(d)
+	case "mergepatch":
+		output, err = formatter.NewMergePatchFormatter(left).Format(d)
+		if lossy, ok := err.(*formatter.MergePatchError); ok {
+			// the patch is still written, along with what it loses
+			fmt.Fprintf(stderr, "Warning: %s\n", lossy)
+			err = nil
+		}
+	default:
+		fmt.Fprintf(stderr, "Unknown format `%s`\n", *format)
+		return exitFormat
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to format the diff: %s\n", err)
+		return exitFormat
+	}
+	fmt.Fprint(stdout, output)
+	if d.Modified() {
+		return exitDifferent
+	}
+	return exitSame
+}
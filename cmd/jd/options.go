@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	diff "github.com/mrutkows/go-jsondiff"
+)
+
+// differFlags holds the flags setting the options of the Differ.
+type differFlags struct {
+	ignored           patternList
+	keys              arrayKeysList
+	unordered         patternList
+	tolerance         float64
+	relativeTolerance float64
+}
+
+// newDifferFlags defines the flags of the Differ options on the flag set.
+func newDifferFlags(flags *flag.FlagSet) *differFlags {
+	f := &differFlags{}
+	flags.Var(&f.ignored, "ignore", "ignore the values at the paths matched by the `pattern`, e.g. /**/updatedAt (repeatable)")
+	flags.Var(&f.keys, "keys", "match the items of arrays by keys, given as `pattern=key[,key...]`, e.g. /countries=name (repeatable)")
+	flags.Var(&f.unordered, "unordered", "compare the arrays at the paths matched by the `pattern` as multisets (repeatable)")
+	flags.Float64Var(&f.tolerance, "tolerance", 0, "compare the numbers which differ by at most this `amount` as equal")
+	flags.Float64Var(&f.relativeTolerance, "relative-tolerance", 0, "compare the numbers which differ by at most this `ratio` of their magnitude as equal")
+	return f
+}
+
+// options returns the options of the Differ set by the flags.
+func (f *differFlags) options() []diff.Option {
+	options := make([]diff.Option, 0)
+	if len(f.ignored) > 0 {
+		options = append(options, diff.Ignore(f.ignored...))
+	}
+	for _, keys := range f.keys {
+		options = append(options, diff.ArrayKeys(keys.pattern, keys.keys...))
+	}
+	if len(f.unordered) > 0 {
+		options = append(options, diff.UnorderedArrays(f.unordered...))
+	}
+	if f.tolerance > 0 || f.relativeTolerance > 0 {
+		options = append(options, diff.Tolerance(f.tolerance, f.relativeTolerance))
+	}
+	return options
+}
+
+// A patternList is a repeatable flag of path patterns.
+type patternList []string
+
+func (l *patternList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *patternList) Set(pattern string) error {
+	if err := diff.ValidatePattern(pattern); err != nil {
+		return err
+	}
+	*l = append(*l, pattern)
+	return nil
+}
+
+type arrayKeys struct {
+	pattern string
+	keys    []string
+}
+
+// An arrayKeysList is a repeatable flag of patterns and array keys.
+type arrayKeysList []arrayKeys
+
+func (l *arrayKeysList) String() string {
+	values := make([]string, len(*l))
+	for i, keys := range *l {
+		values[i] = keys.pattern + "=" + strings.Join(keys.keys, ",")
+	}
+	return strings.Join(values, " ")
+}
+
+func (l *arrayKeysList) Set(value string) error {
+	separator := strings.LastIndex(value, "=")
+	if separator < 0 || separator == len(value)-1 {
+		return fmt.Errorf("invalid array keys `%s`: expected pattern=key[,key...]", value)
+	}
+	var pattern patternList
+	if err := pattern.Set(value[:separator]); err != nil {
+		return err
+	}
+	*l = append(*l, arrayKeys{pattern: pattern[0], keys: strings.Split(value[separator+1:], ",")})
+	return nil
+}
+
+// envString returns the value of the environment variable, or the default
+// value when it is not set.
+func envString(name string, defaultValue string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// envBool returns true if the environment variable is set to a true value.
+func envBool(name string) bool {
+	value, _ := strconv.ParseBool(os.Getenv(name))
+	return value
+}
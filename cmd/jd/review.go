@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+
+	diff "github.com/mrutkows/go-jsondiff"
+	"github.com/mrutkows/go-jsondiff/formatter"
+)
+
+const reviewHelp = `a  accept the change
+r  reject the change
+e  accept the change with another value, given as JSON after the answer
+   or on the next line, e.g. "e 42"
+s  reject the change and the remaining changes within the value it changes
+q  reject the remaining changes and write the result
+?  print this help
+`
+
+// review runs the review command, which asks whether to accept each change
+// from the left document to the right one and writes the left document
+// patched with the accepted changes, or the delta of the accepted changes.
+// Prompts are written to stderr so that the result can go to stdout.
+func review(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	decisions := flags.String("decisions", "", "read the answers from `file` instead of the standard input, one per line")
+	output := flags.String("o", "", "write the result to `file` instead of the standard output")
+	delta := flags.Bool("delta", false, "write the delta of the accepted changes instead of the patched document")
+	coloring := flags.Bool("c", false, "color the changes")
+	context := flags.Int("context", 3, "number of `lines` shown around each change")
+	options := newDifferFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, "Usage: jd review [options] left.json right.json\n\nOptions:\n")
+		flags.PrintDefaults()
+		fmt.Fprint(stderr, "\nAnswers:\n"+reviewHelp)
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	if *decisions == "" && (flags.Arg(0) == stdinName || flags.Arg(1) == stdinName) {
+		fmt.Fprintln(stderr, "The standard input is read for the answers, a decisions file is needed to read a document from it")
+		return exitError
+	}
+	left, right, err := readDocuments(flags.Arg(0), flags.Arg(1), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitCode(err)
+	}
+	answers := stdin
+	if *decisions != "" {
+		file, err := os.Open(*decisions)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to open the decisions file: %s\n", err)
+			return exitError
+		}
+		defer file.Close()
+		answers = file
+	}
+
+	differ := diff.New(options.options()...)
+	r := &reviewer{
+		left:      left,
+		diff:      differ.CompareValues(left, right),
+		answers:   bufio.NewScanner(answers),
+		prompts:   stderr,
+		context:   *context,
+		coloring:  *coloring,
+		echo:      *decisions != "",
+		decisions: make(map[diff.Delta]bool),
+	}
+	r.run()
+
+	patched, applied := differ.ApplySelected(left, r.diff, r.decide)
+	var result string
+	if *delta {
+		result, err = formatter.NewDeltaFormatter().Format(applied)
+	} else {
+		var encoded []byte
+		encoded, err = json.MarshalIndent(patched, "", "  ")
+		result = string(encoded) + "\n"
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to format the result: %s\n", err)
+		return exitFormat
+	}
+	if err := writeOutput(*output, stdout, result); err != nil {
+		fmt.Fprintf(stderr, "Failed to write the result: %s\n", err)
+		return exitError
+	}
+	return exitSame
+}
+
+// A reviewer asks for a decision on each change of a Diff.
+type reviewer struct {
+	left      interface{}
+	diff      diff.Diff
+	answers   *bufio.Scanner
+	prompts   io.Writer
+	context   int
+	coloring  bool
+	echo      bool                // echo the answers which are not typed
+	decisions map[diff.Delta]bool // by delta, missing for rejected changes
+}
+
+// decide returns the decision made on a change.
+func (r *reviewer) decide(change diff.Change) bool {
+	return r.decisions[change.Delta]
+}
+
+// run asks for the decisions until all the changes are decided, the answers
+// run out or the review is quit. Undecided changes are rejected.
+func (r *reviewer) run() {
+	changes := r.diff.Changes()
+	for i, change := range changes {
+		if _, decided := r.decisions[change.Delta]; decided {
+			continue
+		}
+		fmt.Fprintf(r.prompts, "[%d/%d] %s %s\n", i+1, len(changes), change.Kind(), change.Pointer())
+		fmt.Fprint(r.prompts, r.excerpt(change))
+		for asking := true; asking; {
+			fmt.Fprint(r.prompts, "Accept this change [a,r,e,s,q,?]? ")
+			answer, ok := r.readAnswer()
+			if !ok {
+				fmt.Fprintln(r.prompts, "\nNo more answers, the remaining changes are rejected.")
+				return
+			}
+			verb, argument := answer, ""
+			if space := strings.IndexAny(answer, " \t"); space >= 0 {
+				verb, argument = answer[:space], strings.TrimSpace(answer[space:])
+			}
+			asking = false
+			switch verb {
+			case "a":
+				r.decisions[change.Delta] = true
+			case "r":
+				r.decisions[change.Delta] = false
+			case "e":
+				asking = !r.edit(change, argument)
+			case "s":
+				for _, other := range changes[i:] {
+					if within(other.PrePath, change.PrePath) || within(other.PostPath, change.PostPath) {
+						r.decisions[other.Delta] = false
+					}
+				}
+			case "q":
+				return
+			case "?":
+				fmt.Fprint(r.prompts, reviewHelp)
+				asking = true
+			default:
+				fmt.Fprintf(r.prompts, "Unknown answer `%s`\n", answer)
+				fmt.Fprint(r.prompts, reviewHelp)
+				asking = true
+			}
+		}
+	}
+}
+
+// readAnswer returns the next answer which is neither blank nor a comment.
+func (r *reviewer) readAnswer() (string, bool) {
+	for r.answers.Scan() {
+		answer := strings.TrimSpace(r.answers.Text())
+		if answer != "" && !strings.HasPrefix(answer, "#") {
+			if r.echo {
+				fmt.Fprintln(r.prompts, answer)
+			}
+			return answer, true
+		}
+	}
+	return "", false
+}
+
+// edit accepts the change with the value given as JSON, read from the next
+// answer when it is empty. It returns false when the change can not be
+// edited to the value.
+func (r *reviewer) edit(change diff.Change, value string) bool {
+	if value == "" {
+		fmt.Fprint(r.prompts, "New value (JSON)? ")
+		answer, ok := r.readAnswer()
+		if !ok {
+			return false
+		}
+		value = answer
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		fmt.Fprintf(r.prompts, "Invalid JSON value: %s\n", err)
+		return false
+	}
+	// the deltas belong to the review, their new values are replaced
+	switch d := change.Delta.(type) {
+	case *diff.Added:
+		d.Value = parsed
+	case *diff.Modified:
+		d.NewValue = parsed
+	case *diff.TypeChanged:
+		d.NewValue = parsed
+	case *diff.TextDiff:
+		text, ok := parsed.(string)
+		if !ok {
+			fmt.Fprintln(r.prompts, "The value of a text change must be a string")
+			return false
+		}
+		d.Diff = dmp.New().PatchMake(d.OldValue.(string), text)
+		d.NewValue = text
+	default:
+		fmt.Fprintf(r.prompts, "A %s change has no value to edit\n", change.Kind())
+		return false
+	}
+	r.decisions[change.Delta] = true
+	return true
+}
+
+// excerpt returns the lines of the ASCII diff of the change alone which are
+// around its changed lines.
+func (r *reviewer) excerpt(change diff.Change) string {
+	only := r.diff.Filter(func(other diff.Change) bool { return other.Delta == change.Delta })
+	plain, err := formatter.NewAsciiFormatter(r.left, formatter.AsciiFormatterConfig{ShowArrayIndex: true}).Format(only)
+	if err != nil {
+		return fmt.Sprintf("(%s)\n", err)
+	}
+	text := plain
+	if r.coloring {
+		text, _ = formatter.NewAsciiFormatter(r.left, formatter.AsciiFormatterConfig{ShowArrayIndex: true, Coloring: true}).Format(only)
+	}
+	plainLines := strings.SplitAfter(plain, "\n")
+	lines := strings.SplitAfter(text, "\n")
+	first, last := len(plainLines), -1
+	for i, line := range plainLines {
+		if line != "" && !strings.HasPrefix(line, formatter.AsciiSame) {
+			first, last = minInt(first, i), i
+		}
+	}
+	if last < 0 {
+		return text
+	}
+	first, last = maxInt(first-r.context, 0), minInt(last+r.context, len(lines)-1)
+	return strings.Join(lines[first:last+1], "")
+}
+
+// within returns true if the path is the parent path or one of its
+// descendants, false when either of them is nil.
+func within(path diff.Path, parent diff.Path) bool {
+	if path == nil || parent == nil || len(path) < len(parent) {
+		return false
+	}
+	for i, position := range parent {
+		if path[i].String() != position.String() {
+			return false
+		}
+	}
+	return true
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var _ = Describe("Review", func() {
+	var (
+		dir            string
+		left, right    string
+		stdout, stderr *bytes.Buffer
+		writeFile      func(name, content string) string
+		reviewWith     func(decisions string, options ...string) int
+		decodedStdout  func() interface{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "jd-review")
+		Expect(err).To(BeNil())
+		writeFile = func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+			return path
+		}
+		left = writeFile("left.json", `{"name": "app", "steps": ["build", "lint", "test"], "env": {"LEVEL": "debug", "PORT": 8080}}`)
+		right = writeFile("right.json", `{"name": "service", "steps": ["build", "test", "scan"], "env": {"LEVEL": "info", "PORT": 8081}}`)
+		stdout, stderr = &bytes.Buffer{}, &bytes.Buffer{}
+		reviewWith = func(decisions string, options ...string) int {
+			args := append([]string{"review", "-decisions", writeFile("decisions.txt", decisions)}, options...)
+			return run(append(args, left, right), strings.NewReader(""), stdout, stderr)
+		}
+		decodedStdout = func() interface{} {
+			var decoded interface{}
+			Expect(json.Unmarshal(stdout.Bytes(), &decoded)).To(Succeed())
+			return decoded
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Writes the document with the accepted changes", func() {
+		// changes: /env/LEVEL, /env/PORT, /name, /steps/1, /steps/2
+		Expect(reviewWith("a\nr\n# keep the name\nr\na\nr\n")).To(Equal(0))
+		Expect(decodedStdout()).To(Equal(map[string]interface{}{
+			"name":  "app",
+			"steps": []interface{}{"build", "test"},
+			"env":   map[string]interface{}{"LEVEL": "info", "PORT": 8080.0},
+		}))
+		Expect(stderr.String()).To(ContainSubstring("[1/5] modified /env/LEVEL\n"))
+		Expect(stderr.String()).To(ContainSubstring(`-    "LEVEL": "debug",`))
+		Expect(stderr.String()).To(ContainSubstring(`+    "LEVEL": "info",`))
+	})
+
+	It("Edits the values of the changes", func() {
+		Expect(reviewWith("e \"trace\"\ne\n9000\nr\nr\ne [\"lint\", \"scan\"]\n")).To(Equal(0))
+		Expect(decodedStdout()).To(Equal(map[string]interface{}{
+			"name":  "app",
+			"steps": []interface{}{"build", "lint", "test", []interface{}{"lint", "scan"}},
+			"env":   map[string]interface{}{"LEVEL": "trace", "PORT": 9000.0},
+		}))
+	})
+
+	It("Asks again after an answer which can not be applied", func() {
+		Expect(reviewWith("x\ne {\nr\nr\nr\ne 1\nr\nq\n")).To(Equal(0))
+		Expect(stderr.String()).To(ContainSubstring("Unknown answer `x`"))
+		Expect(stderr.String()).To(ContainSubstring("Invalid JSON value"))
+		Expect(stderr.String()).To(ContainSubstring("A deleted change has no value to edit"))
+		Expect(decodedStdout()).To(Equal(map[string]interface{}{
+			"name":  "app",
+			"steps": []interface{}{"build", "lint", "test"},
+			"env":   map[string]interface{}{"LEVEL": "debug", "PORT": 8080.0},
+		}))
+	})
+
+	It("Skips the change and not its siblings", func() {
+		Expect(reviewWith("s\na\na\na\na\n")).To(Equal(0))
+		Expect(decodedStdout()).To(Equal(map[string]interface{}{
+			"name":  "service",
+			"steps": []interface{}{"build", "test", "scan"},
+			"env":   map[string]interface{}{"LEVEL": "debug", "PORT": 8081.0},
+		}))
+		Expect(stderr.String()).To(ContainSubstring("[2/5] modified /env/PORT\n"))
+	})
+
+	It("Skips the rest of the subtree of a change", func() {
+		left = writeFile("left.json", `{"items": [{"id": 1, "v": "a"}, {"id": 2, "v": "b"}], "name": "app"}`)
+		right = writeFile("right.json", `{"items": [{"id": 2, "v": "c"}, {"id": 1, "v": "a"}], "name": "service"}`)
+		// changes: the move of /items/1 to /items/0, /items/0/v, /name
+		Expect(reviewWith("s\na\n", "-keys", "/items=id")).To(Equal(0))
+		Expect(decodedStdout()).To(Equal(map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": 1.0, "v": "a"},
+				map[string]interface{}{"id": 2.0, "v": "b"},
+			},
+			"name": "service",
+		}))
+		Expect(stderr.String()).NotTo(ContainSubstring("/items/0/v"))
+	})
+
+	It("Rejects the remaining changes when the answers run out", func() {
+		Expect(reviewWith("a\n")).To(Equal(0))
+		Expect(stderr.String()).To(ContainSubstring("No more answers"))
+		Expect(decodedStdout().(map[string]interface{})["name"]).To(Equal("app"))
+	})
+
+	It("Writes the delta of the accepted changes to a file", func() {
+		output := filepath.Join(dir, "delta.json")
+		Expect(reviewWith("r\nr\na\na\nr\n", "-delta", "-o", output)).To(Equal(0))
+		Expect(stdout.Len()).To(Equal(0))
+		content, err := os.ReadFile(output)
+		Expect(err).To(BeNil())
+		var delta interface{}
+		Expect(json.Unmarshal(content, &delta)).To(Succeed())
+		Expect(delta).To(Equal(map[string]interface{}{
+			"name":  []interface{}{"app", "service"},
+			"steps": map[string]interface{}{"_t": "a", "_1": []interface{}{"lint", 0.0, 0.0}},
+		}))
+	})
+
+	It("Fails on missing files", func() {
+		Expect(run([]string{"review", left, filepath.Join(dir, "missing.json")}, strings.NewReader(""), stdout, stderr)).To(Equal(2))
+		Expect(stderr.String()).To(ContainSubstring("Failed to open file"))
+	})
+})
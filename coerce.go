@@ -0,0 +1,89 @@
+package gojsondiff
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+)
+
+// CoerceTypes makes a Differ compare strings, numbers and booleans of
+// different types at the paths matched by the patterns by the values they
+// represent, e.g. "42" and 42 or "true" and true. Such values produce
+// TypeChanged deltas instead of Modified deltas, so that they can be told
+// apart from changed values. With no patterns, all values are matched.
+// The patterns are JSON Pointers where "*" matches any single token and "**"
+// matches any number of tokens. Numbers are compared with the Tolerance of
+// their paths.
+func CoerceTypes(patterns ...string) Option {
+	rule := newEquivalenceRule(patterns)
+	return func(differ *Differ) {
+		differ.coerceTypes = append(differ.coerceTypes, rule)
+	}
+}
+
+// jsonNumberPattern matches the strings which are JSON numbers, with the sign,
+// the integer digits, the fraction digits and the exponent as submatches.
+var jsonNumberPattern = regexp.MustCompile(`^(-?)(0|[1-9][0-9]*)(?:\.([0-9]+))?(?:[eE]([+-]?[0-9]+))?$`)
+
+// coercedNumber returns the number represented by a number or a string.
+func coercedNumber(value interface{}) (json.Number, bool) {
+	switch typed := value.(type) {
+	case float64:
+		return json.Number(strconv.FormatFloat(typed, 'g', -1, 64)), true
+	case json.Number:
+		return typed, true
+	case string:
+		if jsonNumberPattern.MatchString(typed) {
+			return json.Number(typed), true
+		}
+	}
+	return "", false
+}
+
+// coercedBool returns the boolean represented by a boolean or a string.
+func coercedBool(value interface{}) (bool, bool) {
+	switch typed := value.(type) {
+	case bool:
+		return typed, true
+	case string:
+		switch typed {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// equivalentValues returns true if two scalars represent the same number or
+// the same boolean, whatever their types. Numbers are compared by
+// equalNumbers.
+func equivalentValues(left, right interface{}, equalNumbers func(left, right json.Number) bool) bool {
+	if l, ok := coercedNumber(left); ok {
+		r, ok := coercedNumber(right)
+		return ok && equalNumbers(l, r)
+	}
+	if l, ok := coercedBool(left); ok {
+		r, ok := coercedBool(right)
+		return ok && l == r
+	}
+	return false
+}
+
+// exactNumbers compares two numbers exactly by their values.
+func exactNumbers(left, right json.Number) bool {
+	equal, _ := equalJsonNumbers(left, right)
+	return equal
+}
+
+// typeChanged returns true if two values of different types at the path
+// represent the same value for the Differ.
+func (c *comparison) typeChanged(path Path, left, right interface{}) bool {
+	if !matchesAny(c.coerceTypes, path) {
+		return false
+	}
+	return equivalentValues(left, right, func(l, r json.Number) bool {
+		return c.equalNumbers(path, l, r)
+	})
+}
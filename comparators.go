@@ -0,0 +1,95 @@
+package gojsondiff
+
+// A Verdict is the decision of a Comparator on two values.
+type Verdict int
+
+const (
+	// VerdictDefer leaves the comparison to the other comparators and to the
+	// Differ.
+	VerdictDefer Verdict = iota
+	// VerdictEqual makes the values the same, so that they produce no delta.
+	VerdictEqual
+	// VerdictNotEqual makes the values differ, even when the Differ finds them
+	// equal.
+	VerdictNotEqual
+)
+
+// DefaultSimilarity is the similarity returned by a Comparator to keep the
+// similarity computed from the values.
+const DefaultSimilarity = -1.0
+
+// A Comparator compares two values with domain rules, e.g. timestamps in
+// different time zones or URLs with reordered query parameters.
+// For VerdictNotEqual, similarity is the similarity of the values from 0 to 1,
+// which is used to match array items, or DefaultSimilarity.
+type Comparator func(left, right interface{}) (verdict Verdict, similarity float64)
+
+type comparatorRule struct {
+	pattern    *pathPattern                 // nil for a predicate rule
+	predicate  func(value interface{}) bool // nil for a pattern rule
+	comparator Comparator
+}
+
+// CompareWith compares the values at the paths matched by the pattern with
+// the comparator before the Differ does. The pattern is a JSON Pointer where
+// "*" matches any single token and "**" matches any number of tokens.
+// Array items made equal by the comparator are matched as the same items.
+func CompareWith(pattern string, comparator Comparator) Option {
+	parsed := mustParsePathPattern(pattern)
+	rule := comparatorRule{pattern: &parsed, comparator: comparator}
+	return func(differ *Differ) {
+		differ.comparators = append(differ.comparators, rule)
+	}
+}
+
+// CompareMatching compares the values matched by the predicate, both left
+// and right, with the comparator before the Differ does, wherever they are,
+// e.g. all the strings which are RFC 3339 timestamps.
+func CompareMatching(predicate func(value interface{}) bool, comparator Comparator) Option {
+	rule := comparatorRule{predicate: predicate, comparator: comparator}
+	return func(differ *Differ) {
+		differ.comparators = append(differ.comparators, rule)
+	}
+}
+
+// customCompare consults the comparators of the values at the path, the one
+// added last first, and returns the first verdict which is not VerdictDefer.
+func (differ *Differ) customCompare(path Path, left, right interface{}) (verdict Verdict, similarity float64) {
+	for i := len(differ.comparators) - 1; i >= 0; i-- {
+		rule := differ.comparators[i]
+		if rule.pattern != nil && !rule.pattern.match(path) {
+			continue
+		}
+		if rule.predicate != nil && !(rule.predicate(left) && rule.predicate(right)) {
+			continue
+		}
+		if verdict, similarity := rule.comparator(left, right); verdict != VerdictDefer {
+			return verdict, similarity
+		}
+	}
+	return VerdictDefer, DefaultSimilarity
+}
+
+// withSimilarity sets the similarity of a delta returned by compareValues,
+// unless it is DefaultSimilarity.
+func withSimilarity(delta Delta, similarity float64) Delta {
+	if similarity < 0 {
+		return delta
+	}
+	if similarity > 1 {
+		similarity = 1
+	}
+	switch d := delta.(type) {
+	case *Object:
+		d.similarityCache.value = similarity
+	case *Array:
+		d.similarityCache.value = similarity
+	case *Modified:
+		d.similarityCache.value = similarity
+	case *TextDiff:
+		d.similarityCache.value = similarity
+	case *TypeChanged:
+		d.similarityCache.value = similarity
+	}
+	return delta
+}
@@ -0,0 +1,473 @@
+package gojsondiff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Compose squashes two consecutive Diffs, from v1 to v2 and from v2 to v3,
+// into a single Diff from v1 to v3 without the documents. Values added then
+// deleted are left out, successive modifications become one and the indexes
+// of array items are rewritten for the changes of both Diffs.
+// An error is returned when the second Diff does not follow the first one,
+// e.g. it modifies a value deleted by the first Diff, or when text diffs
+// without their values, e.g. unmarshalled ones, must be combined.
+func Compose(first, second Diff) (Diff, error) {
+	delta, err := composeDelta(Root{}, wholeDelta(first.Deltas()), wholeDelta(second.Deltas()))
+	if err != nil {
+		return nil, err
+	}
+	ignored := append([]string{}, first.Ignored()...)
+	for _, path := range second.Ignored() {
+		if !containsString(ignored, path) {
+			ignored = append(ignored, path)
+		}
+	}
+	return &diff{deltas: partDeltas(delta), ignored: ignored}, nil
+}
+
+// composeDelta composes the delta of a value made by the first Diff and the
+// delta of the same value made by the second one, and returns the composed
+// delta at the position, nil when the value ends up unchanged.
+func composeDelta(position Position, first Delta, second Delta) (Delta, error) {
+	switch {
+	case first == nil:
+		return repositioned(second, position), nil
+	case second == nil:
+		return repositioned(first, position), nil
+	}
+	fail := func() (Delta, error) {
+		return nil, notConsecutive("delta type '%T' at `%s` can not follow delta type '%T'", second, position, first)
+	}
+
+	switch f := first.(type) {
+	case *Added:
+		if _, ok := second.(*Deleted); ok {
+			return nil, nil
+		}
+		if _, ok := second.(*Added); ok {
+			return fail()
+		}
+		value, err := patchValue(second, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		return NewAdded(position, value), nil
+	case *Deleted:
+		s, ok := second.(*Added)
+		if !ok {
+			return fail()
+		}
+		return replacement(position, first, second, f.Value, s.Value)
+	}
+
+	// the value exists on both sides of the first delta
+	switch s := second.(type) {
+	case *Added:
+		return fail()
+	case *Deleted:
+		value, err := unpatchValue(first, s.Value)
+		if err != nil {
+			return nil, err
+		}
+		return NewDeleted(position, value), nil
+	case *Object:
+		if f, ok := first.(*Object); ok {
+			deltas, err := composeObject(f.Deltas, s.Deltas)
+			if err != nil || len(deltas) == 0 {
+				return nil, err
+			}
+			return NewObject(position, deltas), nil
+		}
+	case *Array:
+		if f, ok := first.(*Array); ok {
+			deltas, err := composeArray(f.Deltas, s.Deltas)
+			if err != nil || len(deltas) == 0 {
+				return nil, err
+			}
+			return NewArray(position, deltas), nil
+		}
+	}
+
+	left, right, err := firstLeftValue(first, second)
+	if err != nil {
+		return nil, err
+	}
+	return replacement(position, first, second, left, right)
+}
+
+// firstLeftValue returns the value before the first delta and after the
+// second one, for deltas which both change an existing value.
+func firstLeftValue(first Delta, second Delta) (left interface{}, right interface{}, err error) {
+	middle, ok := oldValue(second)
+	if !ok {
+		// second is an Object or an Array, first replaced the whole value
+		if text, ok := first.(*TextDiff); ok && text.NewValue == nil {
+			return nil, nil, fmt.Errorf("delta type '%T' without its values can not be composed", first)
+		}
+		if middle, ok = newValue(first); !ok {
+			return nil, nil, notConsecutive("delta type '%T' can not follow delta type '%T'", second, first)
+		}
+	}
+	if left, ok = oldValue(first); !ok {
+		if left, err = unpatchValue(first, middle); err != nil {
+			return nil, nil, err
+		}
+	}
+	right, err = patchValue(second, middle)
+	return left, right, err
+}
+
+// replacement returns the delta replacing the left value by the right one.
+// Text diffs produce text diffs, and type changes produce type changes when
+// the values are still equivalent.
+func replacement(position Position, first Delta, second Delta, left interface{}, right interface{}) (Delta, error) {
+	if reflect.DeepEqual(left, right) {
+		return nil, nil
+	}
+	leftText, leftOk := left.(string)
+	rightText, rightOk := right.(string)
+	_, firstText := first.(*TextDiff)
+	_, secondText := second.(*TextDiff)
+	if leftOk && rightOk && (firstText || secondText) {
+		return NewTextDiff(position, dmp.New().PatchMake(leftText, rightText), left, right), nil
+	}
+	_, firstType := first.(*TypeChanged)
+	_, secondType := second.(*TypeChanged)
+	if (firstType || secondType) && reflect.TypeOf(left) != reflect.TypeOf(right) && equivalentValues(left, right, exactNumbers) {
+		return NewTypeChanged(position, left, right), nil
+	}
+	return NewModified(position, left, right), nil
+}
+
+// oldValue returns the value replaced by a delta which replaces a whole value.
+func oldValue(delta Delta) (interface{}, bool) {
+	switch d := delta.(type) {
+	case *Modified:
+		return d.OldValue, true
+	case *TypeChanged:
+		return d.OldValue, true
+	case *TextDiff:
+		return d.OldValue, d.OldValue != nil
+	}
+	return nil, false
+}
+
+// patchValue returns a copy of the value changed by the delta.
+func patchValue(delta Delta, value interface{}) (interface{}, error) {
+	switch d := delta.(type) {
+	case nil:
+		return value, nil
+	case *Object, *Array:
+		// the patcher reports the deltas which do not apply instead of
+		// panicking on values of the wrong type
+		p := &patcher{comparison: New().newComparison(context.Background(), false)}
+		patched, _ := p.applyToValue(Path{}, deepCopy(value), delta)
+		if len(p.conflicts) > 0 {
+			return nil, notConsecutive("%s", p.conflicts[0])
+		}
+		return patched, nil
+	case *Modified:
+		return d.NewValue, nil
+	case *TypeChanged:
+		return d.NewValue, nil
+	case *TextDiff:
+		text, ok := value.(string)
+		if !ok {
+			return nil, notConsecutive("delta type '%T' at `%s` does not apply to a %T", delta, d.PostPosition(), value)
+		}
+		patched, successes := dmp.New().PatchApply(d.Diff, text)
+		for _, success := range successes {
+			if !success {
+				return nil, fmt.Errorf("text diff at `%s` does not apply", d.PostPosition())
+			}
+		}
+		return patched, nil
+	case *Added:
+		return d.Value, nil
+	}
+	return nil, fmt.Errorf("delta type '%T' does not change a value", delta)
+}
+
+// notConsecutive returns the error of Diffs which do not follow each other.
+func notConsecutive(format string, args ...interface{}) error {
+	return fmt.Errorf("diffs are not consecutive: "+format, args...)
+}
+
+// unpatchValue returns a copy of the value before the delta changed it.
+func unpatchValue(delta Delta, value interface{}) (interface{}, error) {
+	if delta == nil {
+		return value, nil
+	}
+	return patchValue(reverseDelta(delta, func(position Position) Position { return position }), value)
+}
+
+// composeObject composes the deltas of the members of an object.
+func composeObject(first []Delta, second []Delta) ([]Delta, error) {
+	// renamed members are composed as deleted and added members
+	first, err := expandRenames(first)
+	if err != nil {
+		return nil, err
+	}
+	second, err = expandRenames(second)
+	if err != nil {
+		return nil, err
+	}
+	byName := func(deltas []Delta) map[string]Delta {
+		named := make(map[string]Delta, len(deltas))
+		for _, delta := range deltas {
+			named[deltaPosition(delta).String()] = delta
+		}
+		return named
+	}
+	firstDeltas, secondDeltas := byName(first), byName(second)
+	names := make([]string, 0, len(firstDeltas)+len(secondDeltas))
+	for name := range firstDeltas {
+		names = append(names, name)
+	}
+	for name := range secondDeltas {
+		if _, ok := firstDeltas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	deltas := make([]Delta, 0, len(names))
+	for _, name := range names {
+		delta, err := composeDelta(Name(name), firstDeltas[name], secondDeltas[name])
+		if err != nil {
+			return nil, err
+		}
+		if delta != nil {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas, nil
+}
+
+// A composedItem is an item of an array changed by two Diffs.
+type composedItem struct {
+	// pre is the index of the item before the first Diff, -1 for items added
+	// by one of the Diffs, whose value is added
+	pre   int
+	added interface{}
+	// first and second are the changes of the item made by each Diff
+	first  Delta
+	second Delta
+	// left and middle are the values of the item before the first and
+	// the second Diff, when they are known
+	left   interface{}
+	middle interface{}
+}
+
+// composeArray composes the deltas of an array. Both Diffs are simulated on
+// the items of an array long enough for all their indexes, whose items
+// left unchanged by both Diffs produce no delta.
+func composeArray(first []Delta, second []Delta) ([]Delta, error) {
+	length := requiredLength(first)
+	middle := length + len(newArrayIndexMap(first).inserted) - len(newArrayIndexMap(first).removed)
+	if required := requiredLength(second); required > middle {
+		length += required - middle
+	}
+
+	items := make([]*composedItem, length)
+	for i := range items {
+		items[i] = &composedItem{pre: i}
+	}
+	deleted := make(map[int]interface{}) // left values of deleted items
+
+	items, err := simulateDeltas(items, first, func(item *composedItem, delta Delta) {
+		switch d := delta.(type) {
+		case *Deleted:
+			deleted[item.pre] = d.Value
+		case *Moved:
+			item.left = d.Value
+			item.first, _ = d.Delta.(Delta)
+		default:
+			item.first = delta
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	var unpatchErr error
+	items, err = simulateDeltas(items, second, func(item *composedItem, delta Delta) {
+		switch d := delta.(type) {
+		case *Deleted:
+			if item.pre >= 0 {
+				var value interface{}
+				if value, unpatchErr = unpatchValue(item.first, d.Value); unpatchErr == nil {
+					deleted[item.pre] = value
+				}
+			}
+		case *Moved:
+			item.middle = d.Value
+			item.second, _ = d.Delta.(Delta)
+		default:
+			item.second = delta
+		}
+	})
+	if err == nil {
+		err = unpatchErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// items whose left indexes increase are kept, the other ones are moved
+	pres := make([]int, len(items))
+	for k, item := range items {
+		pres[k] = item.pre
+	}
+	kept := longestIncreasing(pres)
+
+	deltas := make([]Delta, 0)
+	for i := 0; i < length; i++ {
+		if value, ok := deleted[i]; ok {
+			deltas = append(deltas, NewDeleted(Index(i), value))
+		}
+	}
+	for k, item := range items {
+		if item.pre < 0 {
+			value, err := patchValue(item.second, item.added)
+			if err != nil {
+				return nil, err
+			}
+			deltas = append(deltas, NewAdded(Index(k), value))
+			continue
+		}
+		change, err := composeDelta(Index(k), item.first, item.second)
+		if err != nil {
+			return nil, err
+		}
+		if kept[k] {
+			if change != nil {
+				deltas = append(deltas, change)
+			}
+			continue
+		}
+		left := item.left
+		if left == nil && item.middle != nil {
+			// values of moved items are unknown once unmarshalled
+			left, _ = unpatchValue(item.first, item.middle)
+		}
+		deltas = append(deltas, NewMoved(Index(item.pre), Index(k), left, change))
+	}
+	return deltas, nil
+}
+
+// simulateDeltas applies array deltas to composed items and reports the
+// delta of each item to record. Added items are new composed items.
+func simulateDeltas(items []*composedItem, deltas []Delta, record func(item *composedItem, delta Delta)) ([]*composedItem, error) {
+	pres := make(preDeltas, 0)
+	posts := make(postDeltas, 0)
+	for _, delta := range deltas {
+		if pre, ok := delta.(PreDelta); ok {
+			pres = append(pres, pre)
+		}
+		if post, ok := delta.(PostDelta); ok {
+			posts = append(posts, post)
+		}
+	}
+	sort.Sort(pres)
+	sort.Stable(posts)
+
+	moved := make(map[*Moved]*composedItem)
+	for _, delta := range pres {
+		i := int(delta.PrePosition().(Index))
+		if i >= len(items) {
+			return nil, fmt.Errorf("delta type '%T' at index %d is out of range", delta, i)
+		}
+		record(items[i], delta.(Delta))
+		if d, ok := delta.(*Moved); ok {
+			moved[d] = items[i]
+		}
+		items = append(items[:i], items[i+1:]...)
+	}
+	for _, delta := range posts {
+		i := int(delta.PostPosition().(Index))
+		switch d := delta.(type) {
+		case *Added:
+			if i > len(items) {
+				return nil, fmt.Errorf("delta type '%T' at index %d is out of range", delta, i)
+			}
+			items = insertComposedItem(items, i, &composedItem{pre: -1, added: d.Value})
+		case *Moved:
+			if i > len(items) {
+				return nil, fmt.Errorf("delta type '%T' at index %d is out of range", delta, i)
+			}
+			items = insertComposedItem(items, i, moved[d])
+		default:
+			if i >= len(items) {
+				return nil, fmt.Errorf("delta type '%T' at index %d is out of range", delta, i)
+			}
+			if items[i].pre < 0 {
+				// a change of an added item changes its value
+				value, err := patchValue(delta.(Delta), items[i].added)
+				if err != nil {
+					return nil, err
+				}
+				items[i] = &composedItem{pre: -1, added: value}
+				continue
+			}
+			record(items[i], delta.(Delta))
+		}
+	}
+	return items, nil
+}
+
+func insertComposedItem(items []*composedItem, index int, item *composedItem) []*composedItem {
+	items = append(items, nil)
+	copy(items[index+1:], items[index:])
+	items[index] = item
+	return items
+}
+
+// requiredLength returns the minimum length of an array the array deltas
+// apply to, so that their indexes are in range.
+func requiredLength(deltas []Delta) int {
+	length, removed := 0, 0
+	posts := make(postDeltas, 0)
+	for _, delta := range deltas {
+		if pre, ok := delta.(PreDelta); ok {
+			length = maxInt(length, int(pre.PrePosition().(Index))+1)
+			removed++
+		}
+		if post, ok := delta.(PostDelta); ok {
+			posts = append(posts, post)
+		}
+	}
+	sort.Stable(posts)
+
+	current := length - removed
+	for _, delta := range posts {
+		needed := int(delta.PostPosition().(Index))
+		switch delta.(type) {
+		case *Added, *Moved:
+		default:
+			needed++
+		}
+		if current < needed {
+			length += needed - current
+			current = needed
+		}
+		switch delta.(type) {
+		case *Added, *Moved:
+			current++
+		}
+	}
+	return length
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
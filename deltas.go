@@ -1,6 +1,7 @@
 package gojsondiff
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -72,6 +73,24 @@ func (i Index) CompareTo(another Position) bool {
 	return i < another.(Index)
 }
 
+// A Root is the Position of a Delta that applies to the whole JSON document,
+// e.g. a Modified between two documents whose types differ.
+type Root struct{}
+
+func (r Root) String() (name string) {
+	return ""
+}
+
+func (r Root) CompareTo(another Position) bool {
+	return false
+}
+
+// isRoot returns true if the position is the whole JSON document.
+func isRoot(position Position) bool {
+	_, ok := position.(Root)
+	return ok
+}
+
 // A PreDelta is a Delta that has a position of the left side JSON object.
 // Deltas implements this interface should be applies before PostDeltas.
 type PreDelta interface {
@@ -224,6 +243,9 @@ func NewAdded(position Position, value interface{}) *Added {
 }
 
 func (d *Added) PostApply(object interface{}) interface{} {
+	if isRoot(d.PostPosition()) {
+		return d.Value
+	}
 	switch object.(type) {
 	case map[string]interface{}:
 		object.(map[string]interface{})[string(d.PostPosition().(Name))] = d.Value
@@ -272,6 +294,9 @@ func NewModified(position Position, oldValue, newValue interface{}) *Modified {
 }
 
 func (d *Modified) PostApply(object interface{}) interface{} {
+	if isRoot(d.PostPosition()) {
+		return d.NewValue
+	}
 	switch object.(type) {
 	case map[string]interface{}:
 		// TODO check old value
@@ -290,8 +315,10 @@ func (d *Modified) similarity() (similarity float64) {
 		switch d.OldValue.(type) {
 		case string:
 			similarity += 0.4 * stringSimilarity(d.OldValue.(string), d.NewValue.(string))
-		case float64:
-			ratio := d.OldValue.(float64) / d.NewValue.(float64)
+		case float64, json.Number:
+			oldValue, _ := toFloat64(d.OldValue)
+			newValue, _ := toFloat64(d.NewValue)
+			ratio := oldValue / newValue
 			if ratio > 1 {
 				ratio = 1 / ratio
 			}
@@ -319,6 +346,16 @@ func NewTextDiff(position Position, diff []diffmatchpatch.Patch, oldValue, newVa
 }
 
 func (d *TextDiff) PostApply(object interface{}) interface{} {
+	if isRoot(d.PostPosition()) {
+		// a document which the patches do not apply to is left as it is
+		oldValue, newValue := d.OldValue, d.NewValue
+		d.OldValue = object
+		if err := d.patch(); err != nil {
+			d.OldValue, d.NewValue = oldValue, newValue
+			return object
+		}
+		return d.NewValue
+	}
 	switch object.(type) {
 	case map[string]interface{}:
 		o := object.(map[string]interface{})
@@ -343,8 +380,12 @@ func (d *TextDiff) patch() error {
 	if d.OldValue == nil {
 		return errors.New("TextDiff: patch(): delta.OldValue is nil")
 	}
+	text, ok := d.OldValue.(string)
+	if !ok {
+		return errors.New("TextDiff: patch(): delta.OldValue is not a string")
+	}
 	patcher := diffmatchpatch.New()
-	patched, successes := patcher.PatchApply(d.Diff, d.OldValue.(string))
+	patched, successes := patcher.PatchApply(d.Diff, text)
 	for _, success := range successes {
 		if !success {
 			return fmt.Errorf("TextDiff: patch(): failed to apply a patch. DiffString=\"%v\"", d.DiffString())
@@ -359,6 +400,30 @@ func (d *TextDiff) DiffString() string {
 	return dmp.PatchToText(d.Diff)
 }
 
+// A TypeChanged represents a Modified whose old and new values have different
+// types but represent the same value, e.g. "42" and 42, which a Differ
+// reports with the CoerceTypes option.
+type TypeChanged struct {
+	Modified
+}
+
+// NewTypeChanged returns a TypeChanged
+func NewTypeChanged(position Position, oldValue, newValue interface{}) *TypeChanged {
+	d := TypeChanged{
+		Modified: Modified{
+			postDelta: postDelta{position},
+			OldValue:  oldValue,
+			NewValue:  newValue,
+		},
+	}
+	d.similarityCache = newSimilarityCache(&d)
+	return &d
+}
+
+func (d *TypeChanged) similarity() (similarity float64) {
+	return 0.9 // at the same position with equivalent values
+}
+
 // A "Deleted" type represents deleted field or index of an Object or an Array.
 type Deleted struct {
 	preDelta
@@ -378,6 +443,9 @@ func NewDeleted(position Position, value interface{}) *Deleted {
 }
 
 func (d *Deleted) PreApply(object interface{}) interface{} {
+	if isRoot(d.PrePosition()) {
+		return nil
+	}
 	switch object.(type) {
 	case map[string]interface{}:
 		// TODO check old value
@@ -395,7 +463,8 @@ func (d Deleted) Similarity() (similarity float64) {
 }
 
 // A "Moved" type represents field that is moved, which means the index or name is
-// changed. Note that, in this library, assigning a Moved and a Modified to
+// changed, e.g. an item moved in an array or a renamed member of an object.
+// Note that, in this library, assigning a Moved and a Modified to
 // a single position is not allowed. For compatibility with jsondiffpatch,
 // the Moved in this library can hold the old and new value in it.
 type Moved struct {
@@ -406,6 +475,9 @@ type Moved struct {
 	Value interface{}
 	// The delta applied after moving (for compatibility)
 	Delta interface{}
+
+	// the value taken out by PreApply and put back by PostApply
+	taken interface{}
 }
 
 func NewMoved(oldPosition Position, newPosition Position, value interface{}, delta Delta) *Moved {
@@ -422,11 +494,14 @@ func NewMoved(oldPosition Position, newPosition Position, value interface{}, del
 func (d *Moved) PreApply(object interface{}) interface{} {
 	switch object.(type) {
 	case map[string]interface{}:
-		//not supported
+		o := object.(map[string]interface{})
+		n := string(d.PrePosition().(Name))
+		d.taken = o[n]
+		delete(o, n)
 	case []interface{}:
 		i := int(d.PrePosition().(Index))
 		o := object.([]interface{})
-		d.Value = o[i]
+		d.taken = o[i]
 		object = append(o[:i], o[i+1:]...)
 	}
 	return object
@@ -435,13 +510,13 @@ func (d *Moved) PreApply(object interface{}) interface{} {
 func (d *Moved) PostApply(object interface{}) interface{} {
 	switch object.(type) {
 	case map[string]interface{}:
-		//not supported
+		object.(map[string]interface{})[string(d.PostPosition().(Name))] = d.taken
 	case []interface{}:
 		i := int(d.PostPosition().(Index))
 		o := object.([]interface{})
 		o = append(o, 0) //dummy
 		copy(o[i+1:], o[i:])
-		o[i] = d.Value
+		o[i] = d.taken
 		object = o
 	}
 
@@ -454,11 +529,19 @@ func (d *Moved) PostApply(object interface{}) interface{} {
 
 func (d *Moved) similarity() (similarity float64) {
 	similarity = 0.6 // as type and contents are same
-	ratio := float64(d.PrePosition().(Index)) / float64(d.PostPosition().(Index))
-	if ratio > 1 {
-		ratio = 1 / ratio
+	switch pre := d.PrePosition().(type) {
+	case Index:
+		ratio := float64(pre) / float64(d.PostPosition().(Index))
+		if ratio > 1 {
+			ratio = 1 / ratio
+		}
+		similarity += 0.4 * ratio
+	case Name:
+		// renamed members of an object
+		if post := string(d.PostPosition().(Name)); len(pre) > 0 && len(post) > 0 {
+			similarity += 0.4 * stringSimilarity(string(pre), post)
+		}
 	}
-	similarity += 0.4 * ratio
 	return
 }
 
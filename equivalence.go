@@ -0,0 +1,155 @@
+package gojsondiff
+
+// NullAsMissing makes the object members at the paths matched by the patterns
+// which are null on one side and missing on the other side the same, e.g.
+// NullAsMissing("/spec/**"). With no patterns, all object members are matched.
+// The patterns are JSON Pointers where "*" matches any single token and "**"
+// matches any number of tokens.
+// The deltas hidden by this option are still held by Diff.Strict.
+func NullAsMissing(patterns ...string) Option {
+	rule := newEquivalenceRule(patterns)
+	return func(differ *Differ) {
+		differ.nullAsMissing = append(differ.nullAsMissing, rule)
+	}
+}
+
+// EmptyAsMissing makes the object members at the paths matched by the
+// patterns which are an empty array or an empty object on one side and
+// missing on the other side the same. With no patterns, all object members
+// are matched.
+// The deltas hidden by this option are still held by Diff.Strict.
+func EmptyAsMissing(patterns ...string) Option {
+	rule := newEquivalenceRule(patterns)
+	return func(differ *Differ) {
+		differ.emptyAsMissing = append(differ.emptyAsMissing, rule)
+	}
+}
+
+// An equivalenceRule matches the paths of the members for which an option
+// applies, all members when it has no patterns.
+type equivalenceRule []pathPattern
+
+func newEquivalenceRule(patterns []string) equivalenceRule {
+	rule := make(equivalenceRule, len(patterns))
+	for i, pattern := range patterns {
+		rule[i] = mustParsePathPattern(pattern)
+	}
+	return rule
+}
+
+func (rule equivalenceRule) match(path Path) bool {
+	if len(rule) == 0 {
+		return true
+	}
+	for _, pattern := range rule {
+		if pattern.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(rules []equivalenceRule, path Path) bool {
+	for _, rule := range rules {
+		if rule.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// missingEquivalent returns true if the value of the member at the path is
+// the same as a missing member.
+func (differ *Differ) missingEquivalent(path Path, value interface{}) bool {
+	if _, member := path.position().(Name); !member {
+		return false
+	}
+	switch typed := value.(type) {
+	case nil:
+		return matchesAny(differ.nullAsMissing, path)
+	case map[string]interface{}:
+		return len(typed) == 0 && matchesAny(differ.emptyAsMissing, path)
+	case []interface{}:
+		return len(typed) == 0 && matchesAny(differ.emptyAsMissing, path)
+	}
+	return false
+}
+
+// newDiff returns the Diff of the deltas of a comparison. Deltas of members
+// which are the same as missing members are only held by its strict Diff.
+func (c *comparison) newDiff(deltas []Delta) *diff {
+	if len(c.nullAsMissing) == 0 && len(c.emptyAsMissing) == 0 {
+		return &diff{deltas: deltas, ignored: c.ignored}
+	}
+	strict := &diff{deltas: deltas, ignored: c.ignored}
+	return &diff{deltas: c.withoutEquivalent(Path{}, deltas), ignored: c.ignored, strict: strict}
+}
+
+// withoutEquivalent returns the deltas of the value at the path without the
+// Added and Deleted deltas of members which are the same as missing members.
+// The containers left without deltas are removed, the others are copied so
+// that the deltas are not modified.
+func (c *comparison) withoutEquivalent(path Path, deltas []Delta) []Delta {
+	result := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Added:
+			if c.missingEquivalent(path.child(d.PostPosition()), d.Value) {
+				continue
+			}
+		case *Deleted:
+			if c.missingEquivalent(path.child(d.PrePosition()), d.Value) {
+				continue
+			}
+		case *Object:
+			children := c.withoutEquivalent(path.child(d.PostPosition()), d.Deltas)
+			if len(children) == 0 {
+				continue
+			}
+			delta = NewObject(d.PostPosition(), children)
+		case *Array:
+			children := c.withoutEquivalent(path.child(d.PostPosition()), d.Deltas)
+			if len(children) == 0 {
+				continue
+			}
+			delta = NewArray(d.PostPosition(), children)
+		case *Moved:
+			delta = c.movedWithoutEquivalent(path, d)
+			if delta == nil {
+				continue
+			}
+		}
+		result = append(result, delta)
+	}
+	return result
+}
+
+// movedWithoutEquivalent returns the Moved delta without the equivalent
+// deltas of its value, or nil for a renamed member which is the same as a
+// missing member under both of its names.
+func (c *comparison) movedWithoutEquivalent(path Path, moved *Moved) Delta {
+	nested, _ := moved.Delta.(Delta)
+	if isRename(moved) && c.missingEquivalent(path.child(moved.PrePosition()), moved.Value) {
+		if value, err := patchValue(nested, moved.Value); err == nil && c.missingEquivalent(path.child(moved.PostPosition()), value) {
+			return nil
+		}
+	}
+	if nested == nil {
+		return moved
+	}
+	pruned := c.withoutEquivalent(path, []Delta{nested})
+	var delta Delta
+	if len(pruned) == 1 {
+		delta = pruned[0]
+	}
+	return NewMoved(moved.PrePosition(), moved.PostPosition(), moved.Value, delta)
+}
+
+// Strict returns the Diff holding the deltas hidden by the NullAsMissing and
+// EmptyAsMissing options too, or the Diff itself.
+func (diff *diff) Strict() Diff {
+	if diff.strict == nil {
+		return diff
+	}
+	return diff.strict
+}
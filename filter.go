@@ -0,0 +1,201 @@
+package gojsondiff
+
+import "sort"
+
+// Filter returns a Diff holding the changes of the Diff for which the
+// predicate returns true. The predicate is called with the changes listed by
+// Changes; a Moved delta and the changes of the moved value are kept or
+// dropped independently. Object and Array deltas left without changes are
+// removed and the indexes of array items are rewritten for the dropped
+// changes, so that the Diff still applies to the left document.
+func (d *diff) Filter(predicate func(change Change) bool) Diff {
+	filtered := &diff{deltas: pruneDeltas(Path{}, Path{}, d.deltas, predicate), ignored: d.ignored}
+	if d.strict != nil {
+		filtered.strict = d.strict.Filter(predicate).(*diff)
+	}
+	return filtered
+}
+
+// Select returns a Diff holding the changes of the values at the paths
+// matched by the pattern and of their descendants, e.g. "/spec/**/image".
+// The pattern is a JSON Pointer where "*" matches any single token and "**"
+// matches any number of tokens. Changes are matched by both of their paths.
+func (d *diff) Select(pattern string) (Diff, error) {
+	parsed, err := parsePathPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return d.Filter(func(change Change) bool {
+		return parsed.matchAncestor(change.PrePath) || parsed.matchAncestor(change.PostPath)
+	}), nil
+}
+
+// matchAncestor returns true if the pattern matches the path or one of its
+// ancestors. A nil path is never matched.
+func (pattern pathPattern) matchAncestor(path Path) bool {
+	if path == nil {
+		return false
+	}
+	for length := len(path); length >= 0; length-- {
+		if pattern.match(path[:length]) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneDeltas returns the deltas of the value at prePath in the left document
+// and at postPath in the right one without the changes which are not kept.
+func pruneDeltas(prePath Path, postPath Path, deltas []Delta, keep func(change Change) bool) []Delta {
+	if isArrayDeltas(deltas) {
+		return pruneArray(prePath, postPath, deltas, keep)
+	}
+	pruned := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		change := changeOf(prePath, postPath, delta, nil)
+		if moved, ok := delta.(*Moved); ok && !keep(change) {
+			// the renamed member keeps its name and its changes
+			delta = repositioned(pruneNested(change, keep), moved.PrePosition())
+		} else {
+			delta = pruneDelta(change, keep)
+		}
+		if delta != nil {
+			pruned = append(pruned, delta)
+		}
+	}
+	return pruned
+}
+
+// pruneDelta returns the delta of the change without the changes which are
+// not kept, or nil when none is kept.
+func pruneDelta(change Change, keep func(change Change) bool) Delta {
+	switch d := change.Delta.(type) {
+	case *Object:
+		if deltas := pruneDeltas(change.PrePath, change.PostPath, d.Deltas, keep); len(deltas) > 0 {
+			return NewObject(d.PostPosition(), deltas)
+		}
+		return nil
+	case *Array:
+		if deltas := pruneDeltas(change.PrePath, change.PostPath, d.Deltas, keep); len(deltas) > 0 {
+			return NewArray(d.PostPosition(), deltas)
+		}
+		return nil
+	case *Moved:
+		if !keep(change) {
+			return nil
+		}
+		return NewMoved(d.PrePosition(), d.PostPosition(), d.Value, pruneNested(change, keep))
+	}
+	if keep(change) {
+		return change.Delta
+	}
+	return nil
+}
+
+// pruneNested returns the delta nested in the Moved delta of the change
+// without the changes which are not kept, or nil.
+func pruneNested(change Change, keep func(change Change) bool) Delta {
+	nested, ok := change.Delta.(*Moved).Delta.(Delta)
+	if !ok {
+		return nil
+	}
+	return pruneDelta(Change{Delta: nested, PrePath: change.PrePath, PostPath: change.PostPath}, keep)
+}
+
+// A prunedItem is an item of the right side of an array whose changes are
+// pruned.
+type prunedItem struct {
+	pre    int    // -1 for added items
+	added  *Added // the delta adding the item, or nil
+	moved  *Moved // the kept delta moving the item, or nil
+	change Delta  // the kept changes of the item, or nil
+}
+
+// pruneArray returns the deltas of the array at prePath in the left document
+// and at postPath in the right one without the changes which are not kept.
+// The items whose deletion or move is dropped are put back after the
+// nearest preceding item which stays in place, and the post indexes of all
+// the items are recomputed.
+func pruneArray(prePath Path, postPath Path, deltas []Delta, keep func(change Change) bool) []Delta {
+	indexes := newArrayIndexMap(deltas)
+	byPost := make(map[int]Delta, len(deltas))
+	deleted := make([]*Deleted, 0)
+	reverted := make([]*prunedItem, 0)
+	for _, delta := range deltas {
+		change := changeOf(prePath, postPath, delta, indexes)
+		switch d := delta.(type) {
+		case *Deleted:
+			if keep(change) {
+				deleted = append(deleted, d)
+			} else {
+				reverted = append(reverted, &prunedItem{pre: int(d.PrePosition().(Index))})
+			}
+		case *Moved:
+			if !keep(change) {
+				reverted = append(reverted, &prunedItem{pre: int(d.PrePosition().(Index)), change: pruneNested(change, keep)})
+				continue
+			}
+			byPost[int(d.PostPosition().(Index))] = NewMoved(d.PrePosition(), d.PostPosition(), d.Value, pruneNested(change, keep))
+		case *Added:
+			if keep(change) {
+				byPost[int(d.PostPosition().(Index))] = d
+			}
+		case PostDelta:
+			if pruned := pruneDelta(change, keep); pruned != nil {
+				byPost[int(d.PostPosition().(Index))] = pruned
+			}
+		}
+	}
+
+	// the items of the right side, which are the items up to the last delta
+	length := requiredLength(deltas) - len(indexes.removed) + len(indexes.inserted)
+	items := make([]*prunedItem, 0, length+len(reverted))
+	insertedAt := make(map[int]bool, len(indexes.inserted))
+	for _, post := range indexes.inserted {
+		insertedAt[post] = true
+	}
+	for k := 0; k < length; k++ {
+		item := &prunedItem{pre: -1}
+		switch d := byPost[k].(type) {
+		case *Added:
+			item.added = d
+		case *Moved:
+			item.pre, item.moved = int(d.PrePosition().(Index)), d
+			item.change, _ = d.Delta.(Delta)
+		default:
+			if insertedAt[k] {
+				// dropped additions and moves
+				continue
+			}
+			item.pre, item.change = indexes.preIndex(k), d
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(reverted, func(i, j int) bool { return reverted[i].pre < reverted[j].pre })
+	for _, item := range reverted {
+		at := 0
+		for k, other := range items {
+			if other.pre >= 0 && other.moved == nil && other.pre < item.pre {
+				at = k + 1
+			}
+		}
+		items = append(items[:at], append([]*prunedItem{item}, items[at:]...)...)
+	}
+
+	pruned := make([]Delta, 0, len(deltas))
+	for _, d := range deleted {
+		pruned = append(pruned, d)
+	}
+	for k, item := range items {
+		switch {
+		case item.added != nil:
+			pruned = append(pruned, repositioned(item.added, Index(k)))
+		case item.moved != nil:
+			pruned = append(pruned, NewMoved(item.moved.PrePosition(), Index(k), item.moved.Value, repositioned(item.change, Index(k))))
+		case item.change != nil:
+			pruned = append(pruned, repositioned(item.change, Index(k)))
+		}
+	}
+	return pruned
+}
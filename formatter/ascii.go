@@ -2,9 +2,10 @@ package formatter
 
 import (
 	"bytes"
-	"errors"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"strconv"
 
 	diff "github.com/mrutkows/go-jsondiff"
 )
@@ -15,6 +16,8 @@ const (
 	AsciiDeleted = "-"
 	AsciiMoved   = "-+"
 	Moved        = "=>"
+	// AsciiTypeChanged marks the old and the new values of a TypeChanged
+	AsciiTypeChanged = "~"
 )
 
 // ANSI color variants
@@ -24,9 +27,10 @@ const (
 )
 
 var AsciiStyles = map[string]string{
-	AsciiDeleted: "30;41", // background red
-	AsciiAdded:   "30;42", // background green
-	AsciiMoved:   "30;43", // background yellow
+	AsciiDeleted:     "30;41", // background red
+	AsciiAdded:       "30;42", // background green
+	AsciiMoved:       "30;43", // background yellow
+	AsciiTypeChanged: "30;46", // background cyan
 }
 
 func NewAsciiFormatter(left interface{}, config AsciiFormatterConfig) *AsciiFormatter {
@@ -65,10 +69,19 @@ func (f *AsciiFormatter) Format(diff diff.Diff) (result string, err error) {
 	f.jsonObjectUnprocessedSize = []int{}
 	f.inArray = []bool{}
 
+	if delta, ok := rootDelta(diff); ok {
+		if err := f.formatRoot(delta); err != nil {
+			return "", err
+		}
+		return f.buffer.String(), nil
+	}
+
 	if v, ok := f.left.(map[string]interface{}); ok {
 		f.formatObject(v, diff)
 	} else if v, ok := f.left.([]interface{}); ok {
 		f.formatArray(v, diff)
+	} else if len(diff.Deltas()) == 0 {
+		f.printRoot(f.left, AsciiSame)
 	} else {
 		return "", fmt.Errorf("expected map[string]interface{} or []interface{}, got %T",
 			f.left)
@@ -93,36 +106,121 @@ func (f *AsciiFormatter) formatArray(left []interface{}, df diff.Diff) {
 	f.addLineWith(AsciiSame, "]")
 }
 
-func (f *AsciiFormatter) processArray(array []interface{}, deltas []diff.Delta) (err error) {
+// formatRoot prints a Delta which replaces the whole document.
+func (f *AsciiFormatter) formatRoot(delta diff.Delta) error {
+	switch deltaType := delta.(type) {
+	case *diff.Added:
+		f.printRoot(deltaType.Value, AsciiAdded)
+	case *diff.Modified:
+		f.printRoot(deltaType.OldValue, AsciiDeleted)
+		f.printRoot(deltaType.NewValue, AsciiAdded)
+	case *diff.TextDiff:
+		f.printRoot(deltaType.OldValue, AsciiDeleted)
+		f.printRoot(deltaType.NewValue, AsciiAdded)
+	case *diff.TypeChanged:
+		f.printRoot(deltaType.OldValue, AsciiTypeChanged)
+		f.printRoot(deltaType.NewValue, AsciiTypeChanged)
+	case *diff.Deleted:
+		f.printRoot(deltaType.Value, AsciiDeleted)
+	default:
+		return fmt.Errorf("unknown Delta type [%T] detected at the root", deltaType)
+	}
+	return nil
+}
 
-	// orderedMap, errOrder := f.createOrderedArrayMap(array, deltas)
-	// if errOrder != nil {
-	// 	return errOrder
-	// }
-	// fmt.Printf("Ordered Array has `%v` entries\n", orderedMap.Len())
+// printRoot prints a whole document with the marker.
+func (f *AsciiFormatter) printRoot(value interface{}, marker string) {
+	switch typedValue := value.(type) {
+	case map[string]interface{}:
+		f.addLineWith(marker, "{")
+		f.push("ROOT", len(typedValue), false)
+		for _, key := range sortedKeys(typedValue) {
+			f.printRecursive(key, typedValue[key], marker)
+		}
+		f.pop()
+		f.addLineWith(marker, "}")
+	case []interface{}:
+		f.addLineWith(marker, "[")
+		f.push("ROOT", len(typedValue), true)
+		for index, item := range typedValue {
+			f.printRecursive(strconv.Itoa(index), item, marker)
+		}
+		f.pop()
+		f.addLineWith(marker, "]")
+	default:
+		f.newLine(marker)
+		f.printValue(value)
+		f.closeLine()
+	}
+}
 
-	patchedIndex := 0
-	for index, value := range array {
-		f.processArrayOrObjectItem(value, deltas, diff.Index(index))
-		patchedIndex++
+// processArray prints the items of the array in their order once patched.
+// Deleted items are printed at their original place, among the kept items,
+// and moved items at their new place with their changes, if any.
+func (f *AsciiFormatter) processArray(array []interface{}, deltas []diff.Delta) (err error) {
+	items, final, err := simulateArray(deltas)
+	if err != nil {
+		return err
+	}
+	// items which are not referred to by the deltas follow the simulated ones
+	for len(items) < len(array) {
+		item := &arrayItem{}
+		items = append(items, item)
+		final = append(final, item)
 	}
 
-	// additional Added
-	for _, delta := range deltas {
-		switch delta.(type) {
+	entries := len(items)
+	for _, item := range final {
+		if _, ok := item.delta.(*diff.Added); ok {
+			entries++
+		}
+	}
+	f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = entries
+
+	// printDeleted prints the deleted items before the left index
+	next := 0
+	printDeleted := func(before int) {
+		for ; next < before; next++ {
+			if deleted, ok := items[next].delta.(*diff.Deleted); ok {
+				f.printRecursive(strconv.Itoa(next), arrayValue(array, next, deleted.Value), AsciiDeleted)
+			}
+		}
+	}
+	for j, item := range final {
+		switch deltaType := item.delta.(type) {
 		case *diff.Added:
-			d := delta.(*diff.Added)
-			// skip items already processed
-			if int(d.Position.(diff.Index)) < len(array) {
-				continue
+			f.printRecursive(strconv.Itoa(j), deltaType.Value, AsciiAdded)
+		case *diff.Moved:
+			i := indexOfArrayItem(items, item)
+			movedString := fmt.Sprintf("%d%s%d", i, Moved, j)
+			if err := f.printMoved(strconv.Itoa(j), func() { f.printKey(movedString) }, arrayValue(array, i, deltaType.Value), deltaType); err != nil {
+				return err
+			}
+		default:
+			i := indexOfArrayItem(items, item)
+			printDeleted(i)
+			next = i + 1
+			if item.delta == nil {
+				f.printRecursive(strconv.Itoa(i), arrayValue(array, i, nil), AsciiSame)
+			} else if err := f.processItem(strconv.Itoa(i), arrayValue(array, i, nil), item.delta); err != nil {
+				return err
 			}
-			f.printRecursive(d.Position.String(), d.Value, AsciiAdded)
 		}
 	}
+	printDeleted(len(items))
 
 	return nil
 }
 
+// arrayValue returns the item of the array at the index, or the value held
+// by its delta when the array is shorter.
+func arrayValue(array []interface{}, index int, value interface{}) interface{} {
+	if index < len(array) {
+		return array[index]
+	}
+	return value
+}
+
 // // TODO - Attempt to get post-delta ordering for array output
 // func (f *AsciiFormatter) createOrderedArrayMap(slice []interface{}, deltas []diff.Delta) (out *orderedmap.OrderedMap[string, interface{}], err error) {
 //
@@ -236,65 +334,8 @@ func (f *AsciiFormatter) processArrayOrObjectItem(value interface{}, deltas []di
 
 	if numDeltaMatches > 0 {
 		for _, matchedDelta := range matchedDeltas {
-
-			switch matchedDeltaType := matchedDelta.(type) {
-			case *diff.Object:
-				mapObject, ok := value.(map[string]interface{})
-				if !ok {
-					return fmt.Errorf("expected: map[string]interface{}: actual type: (%T)", value)
-				}
-				f.newLine(AsciiSame)
-				f.printKey(objectKey)
-				f.print("{")
-				f.closeLine()
-				f.push(objectKey, len(mapObject), false)
-				f.processObject(mapObject, matchedDeltaType.Deltas)
-				f.pop()
-				f.newLine(AsciiSame)
-				f.print("}")
-				f.printComma()
-				f.closeLine()
-
-			case *diff.Array:
-				interfaceSlice, ok := value.([]interface{})
-				if !ok {
-					return fmt.Errorf("expected: []interface{}: actual type: (%T)", value)
-				}
-				f.newLine(AsciiSame)
-				f.printKey(objectKey)
-				f.print("[")
-				f.closeLine()
-				f.push(objectKey, len(interfaceSlice), true)
-				f.processArray(interfaceSlice, matchedDeltaType.Deltas)
-				f.pop()
-				f.newLine(AsciiSame)
-				f.print("]")
-				f.printComma()
-				f.closeLine()
-
-			case *diff.Added:
-				f.printRecursive(objectKey, matchedDeltaType.Value, AsciiAdded)
-				f.printRecursive(objectKey, value, AsciiSame)
-				f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]++
-			case *diff.Modified:
-				savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
-				f.printRecursive(objectKey, matchedDeltaType.OldValue, AsciiDeleted)
-				f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
-				f.printRecursive(objectKey, matchedDeltaType.NewValue, AsciiAdded)
-			case *diff.TextDiff:
-				savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
-				f.printRecursive(objectKey, matchedDeltaType.OldValue, AsciiDeleted)
-				f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
-				f.printRecursive(objectKey, matchedDeltaType.NewValue, AsciiAdded)
-			case *diff.Deleted:
-				f.printRecursive(objectKey, matchedDeltaType.Value, AsciiDeleted)
-			case *diff.Moved:
-				//fmt.Printf("processItem(): valueType: [%T], matchedDelta type: [%T]\n", value, matchedDeltaType)
-				movedString := fmt.Sprintf("%s%s%s", matchedDeltaType.PrePosition().String(), Moved, matchedDeltaType.PostPosition().String())
-				f.printRecursive(movedString, matchedDeltaType.Value, AsciiMoved)
-			default:
-				err := fmt.Errorf("unknown Delta type [%T] detected", matchedDeltaType)
-				return errors.New(err.Error())
+			if err := f.processItem(objectKey, value, matchedDelta); err != nil {
+				return err
 			}
 		}
 	} else {
@@ -304,10 +345,145 @@ func (f *AsciiFormatter) processArrayOrObjectItem(value interface{}, deltas []di
 	return nil
 }
 
+// processItem prints a member of an object or an item of an array changed by
+// the delta.
+func (f *AsciiFormatter) processItem(objectKey string, value interface{}, delta diff.Delta) error {
+	switch matchedDeltaType := delta.(type) {
+	case *diff.Object:
+		mapObject, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected: map[string]interface{}: actual type: (%T)", value)
+		}
+		f.newLine(AsciiSame)
+		f.printKey(objectKey)
+		f.print("{")
+		f.closeLine()
+		f.push(objectKey, len(mapObject), false)
+		f.processObject(mapObject, matchedDeltaType.Deltas)
+		f.pop()
+		f.newLine(AsciiSame)
+		f.print("}")
+		f.printComma()
+		f.closeLine()
+
+	case *diff.Array:
+		interfaceSlice, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected: []interface{}: actual type: (%T)", value)
+		}
+		f.newLine(AsciiSame)
+		f.printKey(objectKey)
+		f.print("[")
+		f.closeLine()
+		f.push(objectKey, len(interfaceSlice), true)
+		f.processArray(interfaceSlice, matchedDeltaType.Deltas)
+		f.pop()
+		f.newLine(AsciiSame)
+		f.print("]")
+		f.printComma()
+		f.closeLine()
+
+	case *diff.Added:
+		f.printRecursive(objectKey, matchedDeltaType.Value, AsciiAdded)
+		f.printRecursive(objectKey, value, AsciiSame)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]++
+	case *diff.Modified:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printRecursive(objectKey, matchedDeltaType.OldValue, AsciiDeleted)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printRecursive(objectKey, matchedDeltaType.NewValue, AsciiAdded)
+	case *diff.TextDiff:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printRecursive(objectKey, matchedDeltaType.OldValue, AsciiDeleted)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printRecursive(objectKey, matchedDeltaType.NewValue, AsciiAdded)
+	case *diff.TypeChanged:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printRecursive(objectKey, matchedDeltaType.OldValue, AsciiTypeChanged)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printRecursive(objectKey, matchedDeltaType.NewValue, AsciiTypeChanged)
+	case *diff.Deleted:
+		f.printRecursive(objectKey, matchedDeltaType.Value, AsciiDeleted)
+	case *diff.Moved:
+		// items of arrays are moved by processArray
+		name := matchedDeltaType.PostPosition().String()
+		return f.printMoved(name, func() {
+			fmt.Fprintf(f.line.buffer, `"%s" %s "%s": `, objectKey, Moved, name)
+		}, value, matchedDeltaType)
+	default:
+		return fmt.Errorf("unknown Delta type [%T] detected", matchedDeltaType)
+	}
+	return nil
+}
+
+// printMoved prints the value of an item or a member moved by a Moved delta,
+// e.g. with the key `"old" => "new"`, along with its changes, if any.
+func (f *AsciiFormatter) printMoved(name string, printKey func(), value interface{}, moved *diff.Moved) error {
+	switch movedDelta := moved.Delta.(type) {
+	case nil:
+		f.printEntry(name, printKey, value, AsciiMoved)
+	case *diff.Object:
+		mapObject, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected: map[string]interface{}: actual type: (%T)", value)
+		}
+		f.newLine(AsciiMoved)
+		printKey()
+		f.print("{")
+		f.closeLine()
+		f.push(name, len(mapObject), false)
+		f.processObject(mapObject, movedDelta.Deltas)
+		f.pop()
+		f.newLine(AsciiMoved)
+		f.print("}")
+		f.printComma()
+		f.closeLine()
+	case *diff.Array:
+		interfaceSlice, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected: []interface{}: actual type: (%T)", value)
+		}
+		f.newLine(AsciiMoved)
+		printKey()
+		f.print("[")
+		f.closeLine()
+		f.push(name, len(interfaceSlice), true)
+		f.processArray(interfaceSlice, movedDelta.Deltas)
+		f.pop()
+		f.newLine(AsciiMoved)
+		f.print("]")
+		f.printComma()
+		f.closeLine()
+	case *diff.TextDiff:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printEntry(name, printKey, movedDelta.OldValue, AsciiDeleted)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printEntry(name, printKey, movedDelta.NewValue, AsciiAdded)
+	case *diff.Modified:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printEntry(name, printKey, movedDelta.OldValue, AsciiDeleted)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printEntry(name, printKey, movedDelta.NewValue, AsciiAdded)
+	case *diff.TypeChanged:
+		savedSize := f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1]
+		f.printEntry(name, printKey, movedDelta.OldValue, AsciiTypeChanged)
+		f.jsonObjectUnprocessedSize[len(f.jsonObjectUnprocessedSize)-1] = savedSize
+		f.printEntry(name, printKey, movedDelta.NewValue, AsciiTypeChanged)
+	default:
+		return fmt.Errorf("unknown Delta type [%T] detected", movedDelta)
+	}
+	return nil
+}
+
 func (f *AsciiFormatter) searchDeltas(deltas []diff.Delta, position diff.Position) (results []diff.Delta) {
 	results = make([]diff.Delta, 0)
 	for _, delta := range deltas {
 		switch deltaType := delta.(type) {
+		case *diff.Moved:
+			// renamed members are printed at their old name
+			if deltaType.PrePosition() == position {
+				results = append(results, delta)
+			}
 		case diff.PostDelta:
 			if deltaType.PostPosition() == position {
 				results = append(results, delta)
@@ -395,6 +571,8 @@ func (f *AsciiFormatter) printValue(value interface{}) {
 		fmt.Fprintf(f.line.buffer, `"%s"`, value)
 	case nil:
 		f.line.buffer.WriteString("null")
+	case json.Number:
+		f.line.buffer.WriteString(value.(json.Number).String())
 	default:
 		fmt.Fprintf(f.line.buffer, `%#v`, value)
 	}
@@ -405,10 +583,15 @@ func (f *AsciiFormatter) print(a string) {
 }
 
 func (f *AsciiFormatter) printRecursive(name string, value interface{}, marker string) {
+	f.printEntry(name, func() { f.printKey(name) }, value, marker)
+}
+
+// printEntry is printRecursive with the key of the value printed by printKey.
+func (f *AsciiFormatter) printEntry(name string, printKey func(), value interface{}, marker string) {
 	switch typedValue := value.(type) {
 	case map[string]interface{}:
 		f.newLine(marker)
-		f.printKey(name)
+		printKey()
 		f.print("{")
 		f.closeLine()
 		size := len(typedValue)
@@ -426,13 +609,13 @@ func (f *AsciiFormatter) printRecursive(name string, value interface{}, marker s
 
 	case []interface{}:
 		f.newLine(marker)
-		f.printKey(name)
+		printKey()
 		f.print("[")
 		f.closeLine()
 		size := len(typedValue)
 		f.push("", size, true)
-		for _, item := range typedValue {
-			f.printRecursive("", item, marker)
+		for index, item := range typedValue {
+			f.printRecursive(strconv.Itoa(index), item, marker)
 		}
 		f.pop()
 		f.newLine(marker)
@@ -442,7 +625,7 @@ func (f *AsciiFormatter) printRecursive(name string, value interface{}, marker s
 
 	default:
 		f.newLine(marker)
-		f.printKey(name)
+		printKey()
 		f.printValue(value)
 		f.printComma()
 		f.closeLine()
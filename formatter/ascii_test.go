@@ -117,6 +117,105 @@ var _ = Describe("Ascii", func() {
 			),
 			)
 		})
+
+		It("Prints changes of the whole document", func() {
+			left := []interface{}{"a", 1.0}
+			d := diff.New().CompareValues(left, "b")
+			Expect(d.Modified()).To(BeTrue())
+
+			f := NewAsciiFormatter(left, AsciiFormatterDefaultConfig)
+			deltaJson, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(deltaJson).To(Equal(
+				`-[
+-  "a",
+-  1
+-]
++"b"
+`,
+			),
+			)
+		})
+
+		It("Prints renamed members", func() {
+			a := map[string]interface{}{"name": "x", "owner": map[string]interface{}{"id": 1.0, "type": "User"}, "size": 3.0, "z": 1.0}
+			b := map[string]interface{}{"title": "x", "author": map[string]interface{}{"id": 1.0, "type": "Org"}, "length": 4.0, "z": 1.0}
+			d := diff.New(diff.DetectRenames(0.5)).CompareObjects(a, b)
+
+			f := NewAsciiFormatter(a, AsciiFormatterDefaultConfig)
+			deltaJson, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(deltaJson).To(Equal(
+				` {
+-+  "name" => "title": "x",
+-+  "owner" => "author": {
+     "id": 1,
+-    "type": "User"
++    "type": "Org"
+-+  },
+-  "size" => "length": 3,
++  "size" => "length": 4,
+   "z": 1
+ }
+`,
+			),
+			)
+		})
+
+		It("Prints moved array items with their changes", func() {
+			a := []interface{}{map[string]interface{}{"name": "alpha", "size": 1.0}, "beta", "gamma", "delta", "omega"}
+			b := []interface{}{"beta", "gamma", "eta", map[string]interface{}{"name": "alpha", "size": 2.0}, "omega", "delta"}
+			d := diff.New(diff.DetectMoves(0.8)).CompareArrays(a, b)
+
+			f := NewAsciiFormatter(a, AsciiFormatterConfig{ShowArrayIndex: true})
+			deltaJson, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(deltaJson).To(Equal(
+				` [
+   1: "beta",
+   2: "gamma",
++  2: "eta",
+-+  0=>3: {
+     "name": "alpha",
+-    "size": 1
++    "size": 2
+-+  },
+-+  4=>4: "omega",
+   3: "delta"
+ ]
+`,
+			),
+			)
+		})
+
+		It("Prints type changes", func() {
+			a := map[string]interface{}{"id": "42", "name": "x"}
+			b := map[string]interface{}{"id": 42.0, "name": "y"}
+			d := diff.New(diff.CoerceTypes()).CompareObjects(a, b)
+
+			f := NewAsciiFormatter(a, AsciiFormatterDefaultConfig)
+			deltaJson, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(deltaJson).To(Equal(
+				` {
+~  "id": "42",
+~  "id": 42,
+-  "name": "x"
++  "name": "y"
+ }
+`,
+			),
+			)
+		})
+
+		It("Prints scalar documents without changes", func() {
+			d := diff.New().CompareValues(12.5, 12.5)
+
+			f := NewAsciiFormatter(12.5, AsciiFormatterDefaultConfig)
+			deltaJson, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(deltaJson).To(Equal(" 12.5\n"))
+		})
 	})
 
 })
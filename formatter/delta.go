@@ -8,9 +8,10 @@ import (
 )
 
 const (
-	DeltaDelete   = 0
-	DeltaTextDiff = 2
-	DeltaMove     = 3
+	DeltaDelete     = 0
+	DeltaTextDiff   = 2
+	DeltaMove       = 3
+	DeltaTypeChange = 4
 )
 
 func NewDeltaFormatter() *DeltaFormatter {
@@ -24,15 +25,15 @@ type DeltaFormatter struct {
 }
 
 func (f *DeltaFormatter) Format(diff diff.Diff) (result string, err error) {
-	jsonObject, err := f.formatObject(diff.Deltas())
+	jsonValue, err := f.formatRoot(diff)
 	if err != nil {
 		return "", err
 	}
 	var resultBytes []byte
 	if f.PrintIndent {
-		resultBytes, err = json.MarshalIndent(jsonObject, "", "  ")
+		resultBytes, err = json.MarshalIndent(jsonValue, "", "  ")
 	} else {
-		resultBytes, err = json.Marshal(jsonObject)
+		resultBytes, err = json.Marshal(jsonValue)
 	}
 	if err != nil {
 		return "", err
@@ -41,10 +42,43 @@ func (f *DeltaFormatter) Format(diff diff.Diff) (result string, err error) {
 	return string(resultBytes) + "\n", nil
 }
 
+// FormatAsJson returns the delta object of a Diff between two objects or two arrays.
+// Deltas of the whole document, e.g. between two documents of different types,
+// are not objects and can only be formatted by Format.
 func (f *DeltaFormatter) FormatAsJson(diff diff.Diff) (json map[string]interface{}, err error) {
+	if delta, ok := rootDelta(diff); ok {
+		return nil, fmt.Errorf("delta type '%T' of the whole document is not an object", delta)
+	}
+	if isArrayDeltas(diff.Deltas()) {
+		return f.formatArray(diff.Deltas())
+	}
 	return f.formatObject(diff.Deltas())
 }
 
+// formatRoot formats a Diff between two documents of any type.
+func (f *DeltaFormatter) formatRoot(df diff.Diff) (deltaJson interface{}, err error) {
+	if delta, ok := rootDelta(df); ok {
+		switch deltaType := delta.(type) {
+		case *diff.Added:
+			return []interface{}{deltaType.Value}, nil
+		case *diff.Modified:
+			return []interface{}{deltaType.OldValue, deltaType.NewValue}, nil
+		case *diff.TextDiff:
+			return []interface{}{deltaType.DiffString(), 0, DeltaTextDiff}, nil
+		case *diff.TypeChanged:
+			return []interface{}{deltaType.OldValue, deltaType.NewValue, DeltaTypeChange}, nil
+		case *diff.Deleted:
+			return []interface{}{deltaType.Value, 0, DeltaDelete}, nil
+		default:
+			return nil, fmt.Errorf("delta type '%T' is not supported at the root", deltaType)
+		}
+	}
+	if isArrayDeltas(df.Deltas()) {
+		return f.formatArray(df.Deltas())
+	}
+	return f.formatObject(df.Deltas())
+}
+
 func (f *DeltaFormatter) formatObject(deltas []diff.Delta) (deltaJson map[string]interface{}, err error) {
 	deltaJson = map[string]interface{}{}
 	for _, delta := range deltas {
@@ -65,10 +99,23 @@ func (f *DeltaFormatter) formatObject(deltas []diff.Delta) (deltaJson map[string
 			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.OldValue, deltaType.NewValue}
 		case *diff.TextDiff:
 			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.DiffString(), 0, DeltaTextDiff}
+		case *diff.TypeChanged:
+			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.OldValue, deltaType.NewValue, DeltaTypeChange}
 		case *diff.Deleted:
 			deltaJson[deltaType.PrePosition().String()] = []interface{}{deltaType.Value, 0, DeltaDelete}
 		case *diff.Moved:
-			return nil, fmt.Errorf("delta type '%T' is not supported in objects", deltaType)
+			// renamed members are moved to their new name, their changes are
+			// placed at the new name
+			deltaJson[deltaType.PrePosition().String()] = []interface{}{"", deltaType.PostPosition().String(), DeltaMove}
+			if movedDelta, ok := deltaType.Delta.(diff.Delta); ok {
+				movedJson, err := f.formatObject([]diff.Delta{movedDelta})
+				if err != nil {
+					return nil, err
+				}
+				for key, value := range movedJson {
+					deltaJson[key] = value
+				}
+			}
 		default:
 			return nil, fmt.Errorf("unknown Delta type detected: %T", deltaType)
 		}
@@ -98,13 +145,58 @@ func (f *DeltaFormatter) formatArray(deltas []diff.Delta) (deltaJson map[string]
 			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.OldValue, deltaType.NewValue}
 		case *diff.TextDiff:
 			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.DiffString(), 0, DeltaTextDiff}
+		case *diff.TypeChanged:
+			deltaJson[deltaType.PostPosition().String()] = []interface{}{deltaType.OldValue, deltaType.NewValue, DeltaTypeChange}
 		case *diff.Deleted:
 			deltaJson["_"+deltaType.PrePosition().String()] = []interface{}{deltaType.Value, 0, DeltaDelete}
 		case *diff.Moved:
 			deltaJson["_"+deltaType.PrePosition().String()] = []interface{}{"", deltaType.PostPosition(), DeltaMove}
+			// changes of the moved item are placed at its new index
+			if movedDelta, ok := deltaType.Delta.(diff.Delta); ok {
+				movedJson, err := f.formatArray([]diff.Delta{movedDelta})
+				if err != nil {
+					return nil, err
+				}
+				for key, value := range movedJson {
+					if key != "_t" {
+						deltaJson[key] = value
+					}
+				}
+			}
 		default:
 			return nil, fmt.Errorf("unknown Delta type detected: %T", deltaType)
 		}
 	}
 	return
 }
+
+// rootDelta returns the Delta of a Diff which applies to the whole document.
+func rootDelta(df diff.Diff) (delta diff.Delta, ok bool) {
+	deltas := df.Deltas()
+	if len(deltas) != 1 {
+		return nil, false
+	}
+	switch deltaType := deltas[0].(type) {
+	case diff.PostDelta:
+		_, ok = deltaType.PostPosition().(diff.Root)
+	case diff.PreDelta:
+		_, ok = deltaType.PrePosition().(diff.Root)
+	}
+	return deltas[0], ok
+}
+
+// isArrayDeltas returns true if the deltas are the changes of an array,
+// i.e. their positions are Indexes.
+func isArrayDeltas(deltas []diff.Delta) bool {
+	for _, delta := range deltas {
+		switch deltaType := delta.(type) {
+		case diff.PostDelta:
+			_, ok := deltaType.PostPosition().(diff.Index)
+			return ok
+		case diff.PreDelta:
+			_, ok := deltaType.PrePosition().(diff.Index)
+			return ok
+		}
+	}
+	return false
+}
@@ -90,6 +90,176 @@ var _ = Describe("Delta", func() {
 			})
 		})
 
+		Context("There are array items matched by a key", func() {
+			It("Places changes of moved items at their new index", func() {
+				a = LoadFixture("../FIXTURES/keyed_from.json")
+				b = LoadFixture("../FIXTURES/keyed_to.json")
+
+				d := diff.New(diff.ArrayKeys("/countries", "name")).CompareObjects(a, b)
+
+				f := NewDeltaFormatter()
+				deltaJson, err := f.FormatAsJson(d)
+				Expect(err).To(BeNil())
+				Expect(deltaJson).To(Equal(
+					map[string]interface{}{
+						"countries": map[string]interface{}{
+							"_t": "a",
+							"0": map[string]interface{}{
+								"capital": []interface{}{"Buenos Aires", "Rawson"},
+							},
+							"_1": []interface{}{
+								map[string]interface{}{"name": "Bolivia", "capital": "La Paz", "unasur": true},
+								0,
+								0,
+							},
+							"_4": []interface{}{"", diff.Index(1), 3},
+							"1": map[string]interface{}{
+								"unasur": []interface{}{true, false},
+							},
+							"4": []interface{}{
+								map[string]interface{}{"name": "Peru", "capital": "Lima", "unasur": true},
+							},
+						},
+					},
+				))
+			})
+		})
+
+		Context("There are reordered items matched by a key", func() {
+			It("Round-trips their changes through the Unmarshaller", func() {
+				item := func(name string, v float64) interface{} {
+					return map[string]interface{}{"name": name, "v": v}
+				}
+				differ := diff.New(diff.ArrayKeys("/c", "name"))
+				b = map[string]interface{}{"c": []interface{}{item("d", 2), item("c", 2), item("b", 2), item("a", 2)}}
+
+				// the deltas are unmarshalled in the random order of map keys
+				for i := 0; i < 20; i++ {
+					a = map[string]interface{}{"c": []interface{}{item("a", 1), item("b", 1), item("c", 1), item("d", 1)}}
+					deltaString, err := NewDeltaFormatter().Format(differ.CompareObjects(a, b))
+					Expect(err).To(BeNil())
+
+					unmarshalled, err := diff.NewUnmarshaller().UnmarshalString(deltaString)
+					Expect(err).To(BeNil())
+					Expect(differ.ApplyPatch(a, unmarshalled)).To(Equal(b))
+				}
+			})
+		})
+
+		Context("There are items moved and changed", func() {
+			It("Round-trips the moves and their changes through the Unmarshaller", func() {
+				text := func(animal string) string {
+					return "the quick brown fox jumps over the lazy " + animal
+				}
+				differ := diff.New(diff.DetectMoves(0.5))
+				right := []interface{}{"x", text("cats"), text("pigs"), text("cows")}
+
+				// the deltas are unmarshalled in the random order of map keys
+				for i := 0; i < 20; i++ {
+					left := []interface{}{text("cow"), text("pig"), text("cat"), "x"}
+					deltaString, err := NewDeltaFormatter().Format(differ.CompareArrays(left, right))
+					Expect(err).To(BeNil())
+					Expect(deltaString).To(ContainSubstring(`"_2": [`))
+
+					unmarshalled, err := diff.NewUnmarshaller().UnmarshalString(deltaString)
+					Expect(err).To(BeNil())
+					patched := differ.ApplyPatch(left, unmarshalled)
+					Expect(patched).To(Equal(right))
+					Expect(differ.Unpatch(patched, unmarshalled)).To(Equal([]interface{}{text("cow"), text("pig"), text("cat"), "x"}))
+				}
+			})
+		})
+
+		Context("The diff is filtered", func() {
+			It("Emits the recomputed indexes", func() {
+				a = LoadFixture("../FIXTURES/keyed_from.json")
+				b = LoadFixture("../FIXTURES/keyed_to.json")
+
+				d := diff.New(diff.ArrayKeys("/countries", "name")).CompareObjects(a, b)
+				filtered := d.Filter(func(change diff.Change) bool {
+					_, deleted := change.Delta.(*diff.Deleted)
+					return !deleted
+				})
+
+				f := NewDeltaFormatter()
+				deltaJson, err := f.FormatAsJson(filtered)
+				Expect(err).To(BeNil())
+				Expect(deltaJson).To(Equal(
+					map[string]interface{}{
+						"countries": map[string]interface{}{
+							"_t": "a",
+							"0": map[string]interface{}{
+								"capital": []interface{}{"Buenos Aires", "Rawson"},
+							},
+							"_4": []interface{}{"", diff.Index(2), 3},
+							"2": map[string]interface{}{
+								"unasur": []interface{}{true, false},
+							},
+							"5": []interface{}{
+								map[string]interface{}{"name": "Peru", "capital": "Lima", "unasur": true},
+							},
+						},
+					},
+				))
+			})
+		})
+
+		Context("There are unordered arrays", func() {
+			It("Round-trips through the Unmarshaller", func() {
+				a = map[string]interface{}{"tags": []interface{}{"red", "green", "blue"}}
+				b = map[string]interface{}{"tags": []interface{}{"blue", "yellow", "red"}}
+
+				differ := diff.New(diff.UnorderedArrays())
+				d := differ.CompareObjects(a, b)
+
+				f := NewDeltaFormatter()
+				deltaString, err := f.Format(d)
+				Expect(err).To(BeNil())
+
+				unmarshalled, err := diff.NewUnmarshaller().UnmarshalString(deltaString)
+				Expect(err).To(BeNil())
+				differ.ApplyPatch(a, unmarshalled)
+				Expect(a["tags"]).To(ConsistOf(b["tags"]))
+			})
+		})
+
+		Context("Numbers are decoded as json.Number", func() {
+			It("Emits the numbers verbatim", func() {
+				d, err := diff.New(diff.UseNumber()).Compare(
+					[]byte(`{"id": 9007199254740993, "price": 1.10}`),
+					[]byte(`{"id": 9007199254740992, "price": 1.20}`),
+				)
+				Expect(err).To(BeNil())
+
+				f := NewDeltaFormatter()
+				f.PrintIndent = false
+				deltaString, err := f.Format(d)
+				Expect(err).To(BeNil())
+				Expect(deltaString).To(Equal(`{"id":[9007199254740993,9007199254740992],"price":[1.10,1.20]}` + "\n"))
+			})
+		})
+
+		Context("There are type changes", func() {
+			It("Marks them and round-trips through the Unmarshaller", func() {
+				a = map[string]interface{}{"id": "42", "enabled": true}
+				b = map[string]interface{}{"id": 42.0, "enabled": "true"}
+				d := diff.New(diff.CoerceTypes()).CompareObjects(a, b)
+
+				f := NewDeltaFormatter()
+				f.PrintIndent = false
+				deltaString, err := f.Format(d)
+				Expect(err).To(BeNil())
+				Expect(deltaString).To(Equal(`{"enabled":[true,"true",4],"id":["42",42,4]}` + "\n"))
+
+				unmarshalled, err := diff.NewUnmarshaller().UnmarshalString(deltaString)
+				Expect(err).To(BeNil())
+				Expect(unmarshalled.Deltas()).To(ConsistOf(
+					diff.NewTypeChanged(diff.Name("enabled"), true, "true"),
+					diff.NewTypeChanged(diff.Name("id"), "42", 42.0),
+				))
+			})
+		})
+
 		Context("There are long texts", func() {
 			It("Returns empty JSON", func() {
 				a = LoadFixture("../FIXTURES/long_text_from.json")
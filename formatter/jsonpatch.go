@@ -0,0 +1,377 @@
+package formatter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	diff "github.com/mrutkows/go-jsondiff"
+)
+
+func NewJsonPatchFormatter() *JsonPatchFormatter {
+	return &JsonPatchFormatter{
+		PrintIndent: true,
+	}
+}
+
+// A JsonPatchFormatter formats a Diff as a JSON Patch (RFC 6902), a list of
+// operations which turns the left side document into the right side one when
+// applied in order.
+type JsonPatchFormatter struct {
+	PrintIndent bool
+	// Tests makes the formatter emit a "test" operation of the old value
+	// before each operation which removes or replaces a value.
+	Tests bool
+}
+
+func (f *JsonPatchFormatter) Format(diff diff.Diff) (result string, err error) {
+	operations, err := f.Operations(diff)
+	if err != nil {
+		return "", err
+	}
+	var resultBytes []byte
+	if f.PrintIndent {
+		resultBytes, err = json.MarshalIndent(operations, "", "  ")
+	} else {
+		resultBytes, err = json.Marshal(operations)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(resultBytes) + "\n", nil
+}
+
+// Operations returns the JSON Patch operations of the Diff.
+func (f *JsonPatchFormatter) Operations(df diff.Diff) (operations []diff.JsonPatchOperation, err error) {
+	operations = []diff.JsonPatchOperation{}
+	if delta, ok := rootDelta(df); ok {
+		return f.formatRoot(operations, delta)
+	}
+	if isArrayDeltas(df.Deltas()) {
+		return f.formatArray(operations, diff.Path{}, df.Deltas())
+	}
+	return f.formatObject(operations, diff.Path{}, df.Deltas())
+}
+
+func (f *JsonPatchFormatter) formatRoot(operations []diff.JsonPatchOperation, delta diff.Delta) ([]diff.JsonPatchOperation, error) {
+	switch deltaType := delta.(type) {
+	case *diff.Added:
+		return append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchAdd, Path: "", Value: deltaType.Value}), nil
+	case *diff.Modified, *diff.TextDiff, *diff.TypeChanged:
+		return f.replace(operations, diff.Path{}, delta)
+	}
+	return nil, fmt.Errorf("delta type '%T' is not supported at the root", delta)
+}
+
+func (f *JsonPatchFormatter) formatObject(operations []diff.JsonPatchOperation, path diff.Path, deltas []diff.Delta) ([]diff.JsonPatchOperation, error) {
+	var err error
+	// members are renamed first, so that their old names can be reused
+	for _, delta := range deltas {
+		if moved, ok := delta.(*diff.Moved); ok {
+			operations, err = f.rename(operations, path, moved)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, delta := range deltas {
+		switch deltaType := delta.(type) {
+		case *diff.Object:
+			operations, err = f.formatObject(operations, childPath(path, deltaType.Position), deltaType.Deltas)
+		case *diff.Array:
+			operations, err = f.formatArray(operations, childPath(path, deltaType.Position), deltaType.Deltas)
+		case *diff.Added:
+			operations = append(operations, diff.JsonPatchOperation{
+				Op:    diff.JsonPatchAdd,
+				Path:  childPath(path, deltaType.Position).String(),
+				Value: deltaType.Value,
+			})
+		case *diff.Modified, *diff.TextDiff, *diff.TypeChanged:
+			operations, err = f.replace(operations, childPath(path, delta.(diff.PostDelta).PostPosition()), delta)
+		case *diff.Deleted:
+			operations = f.remove(operations, childPath(path, deltaType.Position), deltaType.Value)
+		case *diff.Moved:
+			// already renamed
+		default:
+			return nil, fmt.Errorf("unknown Delta type detected: %T", deltaType)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return operations, nil
+}
+
+// formatArray emits the operations of the deltas of an array.
+// The deltas remove items at their pre indexes, in descending order, and then
+// insert items at their post indexes, in ascending order. The operations
+// follow the same order, but moved items are not held out of the array:
+// the items are simulated to compute their index at each step.
+func (f *JsonPatchFormatter) formatArray(operations []diff.JsonPatchOperation, path diff.Path, deltas []diff.Delta) ([]diff.JsonPatchOperation, error) {
+	items, final, err := simulateArray(deltas)
+	if err != nil {
+		return nil, err
+	}
+
+	// removed items
+	for i := len(items) - 1; i >= 0; i-- {
+		if deleted, ok := items[i].delta.(*diff.Deleted); ok {
+			operations = f.remove(operations, childPath(path, diff.Index(i)), deleted.Value)
+			items = append(items[:i], items[i+1:]...)
+		}
+	}
+
+	// added and moved items
+	for j := 0; j < len(final); {
+		if j < len(items) && items[j] == final[j] {
+			j++
+			continue
+		}
+		if !final[j].kept() {
+			if added, ok := final[j].delta.(*diff.Added); ok {
+				operations = append(operations, diff.JsonPatchOperation{
+					Op:    diff.JsonPatchAdd,
+					Path:  childPath(path, diff.Index(j)).String(),
+					Value: added.Value,
+				})
+				items = insertArrayItem(items, j, final[j])
+			} else {
+				k := indexOfArrayItem(items, final[j])
+				operations = f.move(operations, path, k, j, final[j])
+				items = insertArrayItem(append(items[:k], items[k+1:]...), j, final[j])
+			}
+			j++
+			continue
+		}
+		// the item at j is moved further, it is put before the kept item which
+		// follows it, so that kept items stay in place
+		blocking := items[j]
+		target := len(items) - 1
+		for _, item := range final[indexOfArrayItem(final, blocking)+1:] {
+			if item.kept() {
+				target = indexOfArrayItem(items, item) - 1
+				break
+			}
+		}
+		operations = f.move(operations, path, j, target, blocking)
+		items = insertArrayItem(append(items[:j], items[j+1:]...), target, blocking)
+	}
+
+	// changes of items, at their final indexes
+	for j, item := range final {
+		var change diff.Delta
+		if moved, ok := item.delta.(*diff.Moved); ok {
+			change, _ = moved.Delta.(diff.Delta)
+		} else if item.kept() {
+			change = item.delta
+		}
+		if change == nil {
+			continue
+		}
+		itemPath := childPath(path, diff.Index(j))
+		switch deltaType := change.(type) {
+		case *diff.Object:
+			operations, err = f.formatObject(operations, itemPath, deltaType.Deltas)
+		case *diff.Array:
+			operations, err = f.formatArray(operations, itemPath, deltaType.Deltas)
+		case *diff.Modified, *diff.TextDiff, *diff.TypeChanged:
+			operations, err = f.replace(operations, itemPath, change)
+		default:
+			err = fmt.Errorf("unknown Delta type detected: %T", deltaType)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return operations, nil
+}
+
+func (f *JsonPatchFormatter) remove(operations []diff.JsonPatchOperation, path diff.Path, value interface{}) []diff.JsonPatchOperation {
+	pointer := path.String()
+	if f.Tests {
+		operations = append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchTest, Path: pointer, Value: value})
+	}
+	return append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchRemove, Path: pointer})
+}
+
+func (f *JsonPatchFormatter) replace(operations []diff.JsonPatchOperation, path diff.Path, delta diff.Delta) ([]diff.JsonPatchOperation, error) {
+	var oldValue, newValue interface{}
+	switch deltaType := delta.(type) {
+	case *diff.TextDiff:
+		if deltaType.NewValue == nil {
+			return nil, errors.New("text diffs without their values, e.g. unmarshalled ones, are not supported")
+		}
+		oldValue, newValue = deltaType.OldValue, deltaType.NewValue
+	case *diff.Modified:
+		oldValue, newValue = deltaType.OldValue, deltaType.NewValue
+	case *diff.TypeChanged:
+		oldValue, newValue = deltaType.OldValue, deltaType.NewValue
+	}
+	pointer := path.String()
+	if f.Tests {
+		operations = append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchTest, Path: pointer, Value: oldValue})
+	}
+	return append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchReplace, Path: pointer, Value: newValue}), nil
+}
+
+func (f *JsonPatchFormatter) move(operations []diff.JsonPatchOperation, path diff.Path, from int, to int, item *arrayItem) []diff.JsonPatchOperation {
+	fromPointer := childPath(path, diff.Index(from)).String()
+	// values of moved items are unknown once unmarshalled
+	if moved, ok := item.delta.(*diff.Moved); ok && f.Tests && moved.Value != nil {
+		operations = append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchTest, Path: fromPointer, Value: moved.Value})
+	}
+	return append(operations, diff.JsonPatchOperation{
+		Op:   diff.JsonPatchMove,
+		From: fromPointer,
+		Path: childPath(path, diff.Index(to)).String(),
+	})
+}
+
+// rename emits the operations of a member renamed by a Moved delta, a "move"
+// followed by the changes of the member at its new name.
+func (f *JsonPatchFormatter) rename(operations []diff.JsonPatchOperation, path diff.Path, moved *diff.Moved) ([]diff.JsonPatchOperation, error) {
+	from, ok := moved.PrePosition().(diff.Name)
+	to, named := moved.PostPosition().(diff.Name)
+	if !ok || !named {
+		return nil, fmt.Errorf("delta type '%T' has no member name", moved)
+	}
+	fromPointer := childPath(path, from).String()
+	// values of renamed members are unknown once unmarshalled
+	if f.Tests && moved.Value != nil {
+		operations = append(operations, diff.JsonPatchOperation{Op: diff.JsonPatchTest, Path: fromPointer, Value: moved.Value})
+	}
+	operations = append(operations, diff.JsonPatchOperation{
+		Op:   diff.JsonPatchMove,
+		From: fromPointer,
+		Path: childPath(path, to).String(),
+	})
+	if nested, ok := moved.Delta.(diff.Delta); ok {
+		return f.formatObject(operations, path, []diff.Delta{nested})
+	}
+	return operations, nil
+}
+
+// An arrayItem is an item of an array simulated by formatArray.
+// delta is the Deleted, Added or Moved delta of the item, or the Delta
+// changing a kept item, if any.
+type arrayItem struct {
+	delta diff.Delta
+}
+
+// kept returns true if the item is neither deleted, added nor moved.
+func (item *arrayItem) kept() bool {
+	switch item.delta.(type) {
+	case *diff.Deleted, *diff.Added, *diff.Moved:
+		return false
+	}
+	return true
+}
+
+// simulateArray returns the items of the left side of an array and its items
+// once patched by the deltas. Items which are not referred to by the deltas
+// and follow them are left out, so that the arrays are as long as needed.
+func simulateArray(deltas []diff.Delta) (items []*arrayItem, final []*arrayItem, err error) {
+	var pres, posts []diff.Delta
+	length, removed := 0, 0
+	for _, delta := range deltas {
+		if pre, ok := delta.(diff.PreDelta); ok {
+			index, ok := pre.PrePosition().(diff.Index)
+			if !ok {
+				return nil, nil, fmt.Errorf("delta type '%T' has no array index", delta)
+			}
+			length = maxInt(length, int(index)+1)
+			removed++
+			pres = append(pres, delta)
+		}
+		if post, ok := delta.(diff.PostDelta); ok {
+			if _, ok := post.PostPosition().(diff.Index); !ok {
+				return nil, nil, fmt.Errorf("delta type '%T' has no array index", delta)
+			}
+			posts = append(posts, delta)
+		}
+	}
+	sort.SliceStable(pres, func(i, j int) bool { return preIndex(pres[i]) > preIndex(pres[j]) })
+	sort.SliceStable(posts, func(i, j int) bool { return postIndex(posts[i]) < postIndex(posts[j]) })
+
+	// the left side must be long enough for every post index
+	current := length - removed
+	for _, delta := range posts {
+		needed := postIndex(delta)
+		switch delta.(type) {
+		case *diff.Added, *diff.Moved:
+		default:
+			needed++
+		}
+		if current < needed {
+			length += needed - current
+			current = needed
+		}
+		switch delta.(type) {
+		case *diff.Added, *diff.Moved:
+			current++
+		}
+	}
+
+	items = make([]*arrayItem, length)
+	for i := range items {
+		items[i] = &arrayItem{}
+	}
+	final = append([]*arrayItem{}, items...)
+	byDelta := make(map[diff.Delta]*arrayItem)
+	for _, delta := range pres {
+		i := preIndex(delta)
+		items[i].delta = delta
+		byDelta[delta] = items[i]
+		final = append(final[:i], final[i+1:]...)
+	}
+	for _, delta := range posts {
+		j := postIndex(delta)
+		switch delta.(type) {
+		case *diff.Added:
+			final = insertArrayItem(final, j, &arrayItem{delta: delta})
+		case *diff.Moved:
+			final = insertArrayItem(final, j, byDelta[delta])
+		default:
+			final[j].delta = delta
+		}
+	}
+	return items, final, nil
+}
+
+func preIndex(delta diff.Delta) int {
+	return int(delta.(diff.PreDelta).PrePosition().(diff.Index))
+}
+
+func postIndex(delta diff.Delta) int {
+	return int(delta.(diff.PostDelta).PostPosition().(diff.Index))
+}
+
+func insertArrayItem(items []*arrayItem, index int, item *arrayItem) []*arrayItem {
+	items = append(items, nil)
+	copy(items[index+1:], items[index:])
+	items[index] = item
+	return items
+}
+
+func indexOfArrayItem(items []*arrayItem, item *arrayItem) int {
+	for i, candidate := range items {
+		if candidate == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// childPath returns a new Path with the position appended.
+func childPath(path diff.Path, position diff.Position) diff.Path {
+	return append(append(diff.Path{}, path...), position)
+}
+
+func maxInt(first int, second int) int {
+	if first > second {
+		return first
+	}
+	return second
+}
@@ -0,0 +1,207 @@
+package formatter_test
+
+import (
+	. "github.com/mrutkows/go-jsondiff/formatter"
+
+	. "github.com/mrutkows/go-jsondiff/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+
+	diff "github.com/mrutkows/go-jsondiff"
+)
+
+var _ = Describe("JsonPatch", func() {
+	Describe("Format", func() {
+		fixtures := [][2]string{
+			{"base", "base_changed"},
+			{"add_delete_from", "add_delete_to"},
+			{"changed_types_from", "changed_types_to"},
+			{"long_text_from", "long_text_to"},
+			{"move_from", "move_to"},
+			{"keyed_from", "keyed_to"},
+		}
+
+		It("Patches every fixture", func() {
+			for _, fixture := range fixtures {
+				a := LoadFixture("../FIXTURES/" + fixture[0] + ".json")
+				b := LoadFixture("../FIXTURES/" + fixture[1] + ".json")
+				d := diff.New(diff.ArrayKeys("/countries", "name")).CompareObjects(a, b)
+
+				f := NewJsonPatchFormatter()
+				f.Tests = true
+				operations, err := f.Operations(d)
+				Expect(err).To(BeNil())
+				Expect(applyOperations(a, operations)).To(Equal(b), fixture[0])
+			}
+		})
+
+		It("Escapes pointers and emits test operations", func() {
+			d := diff.New().CompareObjects(
+				map[string]interface{}{"a/b": 1.0, "m~n": "x", "list": []interface{}{"a", "b"}},
+				map[string]interface{}{"a/b": 2.0, "list": []interface{}{"b"}, "new": nil},
+			)
+
+			f := NewJsonPatchFormatter()
+			f.PrintIndent = false
+			f.Tests = true
+			result, err := f.Format(d)
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(`[` +
+				`{"op":"test","path":"/a~1b","value":1},` +
+				`{"op":"replace","path":"/a~1b","value":2},` +
+				`{"op":"test","path":"/list/0","value":"a"},` +
+				`{"op":"remove","path":"/list/0"},` +
+				`{"op":"test","path":"/m~0n","value":"x"},` +
+				`{"op":"remove","path":"/m~0n"},` +
+				`{"op":"add","path":"/new","value":null}` +
+				`]` + "\n"))
+		})
+
+		It("Moves array items", func() {
+			d := diff.New().CompareArrays(
+				[]interface{}{"a", "b", "c", "d"},
+				[]interface{}{"d", "a", "b", "c"},
+			)
+
+			operations, err := NewJsonPatchFormatter().Operations(d)
+			Expect(err).To(BeNil())
+			Expect(operations).To(Equal([]diff.JsonPatchOperation{
+				{Op: diff.JsonPatchMove, From: "/3", Path: "/0"},
+			}))
+		})
+
+		It("Replaces the whole document", func() {
+			d := diff.New().CompareValues([]interface{}{1.0}, "text")
+
+			operations, err := NewJsonPatchFormatter().Operations(d)
+			Expect(err).To(BeNil())
+			Expect(operations).To(Equal([]diff.JsonPatchOperation{
+				{Op: diff.JsonPatchReplace, Path: "", Value: "text"},
+			}))
+		})
+
+		It("Patches random arrays", func() {
+			random := rand.New(rand.NewSource(1))
+			items := func() []interface{} {
+				array := make([]interface{}, random.Intn(10))
+				for i := range array {
+					switch random.Intn(3) {
+					case 0:
+						array[i] = map[string]interface{}{"id": float64(random.Intn(4)), "v": float64(random.Intn(2))}
+					case 1:
+						array[i] = []interface{}{float64(random.Intn(3))}
+					default:
+						array[i] = float64(random.Intn(5))
+					}
+				}
+				return array
+			}
+			differs := []*diff.Differ{diff.New(), diff.New(diff.ArrayKeys("", "id"))}
+			for i := 0; i < 500; i++ {
+				a, b := items(), items()
+				d := differs[i%2].CompareArrays(a, b)
+
+				operations, err := NewJsonPatchFormatter().Operations(d)
+				Expect(err).To(BeNil())
+				Expect(applyOperations(a, operations)).To(Equal(b), fmt.Sprintf("%v => %v", a, b))
+			}
+		})
+	})
+})
+
+// applyOperations applies JSON Patch operations to a copy of the document.
+func applyOperations(document interface{}, operations []diff.JsonPatchOperation) interface{} {
+	data, err := json.Marshal(document)
+	Expect(err).To(BeNil())
+	var result interface{}
+	Expect(json.Unmarshal(data, &result)).To(Succeed())
+
+	for _, operation := range operations {
+		switch operation.Op {
+		case diff.JsonPatchTest:
+			Expect(reflect.DeepEqual(getPointer(result, operation.Path), operation.Value)).To(BeTrue(), operation.Path)
+		case diff.JsonPatchRemove:
+			result = setPointer(result, operation.Path, nil, true)
+		case diff.JsonPatchAdd:
+			result = setPointer(result, operation.Path, operation.Value, false)
+		case diff.JsonPatchReplace:
+			result = setPointer(result, operation.Path, nil, true)
+			result = setPointer(result, operation.Path, operation.Value, false)
+		case diff.JsonPatchMove:
+			value := getPointer(result, operation.From)
+			result = setPointer(result, operation.From, nil, true)
+			result = setPointer(result, operation.Path, value, false)
+		}
+	}
+	return result
+}
+
+func splitTestPointer(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+func getPointer(document interface{}, pointer string) interface{} {
+	for _, token := range splitTestPointer(pointer) {
+		switch typed := document.(type) {
+		case map[string]interface{}:
+			document = typed[token]
+		case []interface{}:
+			index, _ := strconv.Atoi(token)
+			document = typed[index]
+		}
+	}
+	return document
+}
+
+// setPointer adds the value at the pointer, or removes the value there, and
+// returns the document.
+func setPointer(document interface{}, pointer string, value interface{}, remove bool) interface{} {
+	return setTokens(document, splitTestPointer(pointer), value, remove)
+}
+
+func setTokens(document interface{}, tokens []string, value interface{}, remove bool) interface{} {
+	if len(tokens) == 0 {
+		return value
+	}
+	last := len(tokens) == 1
+	switch typed := document.(type) {
+	case map[string]interface{}:
+		switch {
+		case !last:
+			typed[tokens[0]] = setTokens(typed[tokens[0]], tokens[1:], value, remove)
+		case remove:
+			delete(typed, tokens[0])
+		default:
+			typed[tokens[0]] = value
+		}
+		return typed
+	case []interface{}:
+		index, _ := strconv.Atoi(tokens[0])
+		switch {
+		case !last:
+			typed[index] = setTokens(typed[index], tokens[1:], value, remove)
+		case remove:
+			typed = append(typed[:index], typed[index+1:]...)
+		default:
+			typed = append(typed, nil)
+			copy(typed[index+1:], typed[index:])
+			typed[index] = value
+		}
+		return typed
+	}
+	return document
+}
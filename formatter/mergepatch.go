@@ -0,0 +1,178 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	diff "github.com/mrutkows/go-jsondiff"
+)
+
+func NewMergePatchFormatter(left interface{}) *MergePatchFormatter {
+	return &MergePatchFormatter{
+		left:        left,
+		PrintIndent: true,
+	}
+}
+
+// A MergePatchFormatter formats a Diff as a JSON Merge Patch (RFC 7386).
+// Merge patches can not express the changes of arrays, which are replaced
+// as a whole, so the formatter needs the left side document of the Diff.
+type MergePatchFormatter struct {
+	left        interface{}
+	PrintIndent bool
+}
+
+// A MergePatchLoss is a change of a Diff which a merge patch can not express.
+type MergePatchLoss struct {
+	// Path is the JSON Pointer of the value.
+	Path string
+	// Reason describes what the merge patch loses.
+	Reason string
+}
+
+// A MergePatchError is returned along with a merge patch which does not
+// produce the right side document of the Diff.
+type MergePatchError struct {
+	Losses []MergePatchLoss
+}
+
+func (e *MergePatchError) Error() string {
+	messages := make([]string, len(e.Losses))
+	for i, loss := range e.Losses {
+		messages[i] = fmt.Sprintf("`%s`: %s", loss.Path, loss.Reason)
+	}
+	return fmt.Sprintf("%d change(s) can not be expressed by a merge patch: %s", len(e.Losses), strings.Join(messages, "; "))
+}
+
+// Format returns the merge patch of the Diff. When some changes can not be
+// expressed, e.g. a member set to null, the merge patch is returned along
+// with a *MergePatchError listing them.
+func (f *MergePatchFormatter) Format(df diff.Diff) (result string, err error) {
+	patch, err := f.MergePatch(df)
+	if _, lossy := err.(*MergePatchError); err != nil && !lossy {
+		return "", err
+	}
+	var resultBytes []byte
+	var marshalErr error
+	if f.PrintIndent {
+		resultBytes, marshalErr = json.MarshalIndent(patch, "", "  ")
+	} else {
+		resultBytes, marshalErr = json.Marshal(patch)
+	}
+	if marshalErr != nil {
+		return "", marshalErr
+	}
+
+	return string(resultBytes) + "\n", err
+}
+
+// MergePatch returns the merge patch of the Diff as a JSON value, along with
+// a *MergePatchError when some changes can not be expressed.
+func (f *MergePatchFormatter) MergePatch(df diff.Diff) (patch interface{}, err error) {
+	right, err := diff.New().Patch(f.left, df)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &mergePatch{}
+	leftObject, isObject := f.left.(map[string]interface{})
+	rightObject, isRightObject := right.(map[string]interface{})
+	_, isRoot := rootDelta(df)
+	switch {
+	case right == nil:
+		// a null merge patch replaces the whole document
+		patch = nil
+	case isObject && isRightObject && !isRoot:
+		patch = m.object(diff.Path{}, df.Deltas(), leftObject, rightObject)
+	default:
+		patch = m.replace(diff.Path{}, f.left, right)
+	}
+	if len(m.losses) > 0 {
+		sort.Slice(m.losses, func(i, j int) bool { return m.losses[i].Path < m.losses[j].Path })
+		return patch, &MergePatchError{Losses: m.losses}
+	}
+	return patch, nil
+}
+
+// A mergePatch holds the losses of a merge patch being formatted.
+type mergePatch struct {
+	losses []MergePatchLoss
+}
+
+func (m *mergePatch) lose(path diff.Path, reason string) {
+	m.losses = append(m.losses, MergePatchLoss{Path: path.String(), Reason: reason})
+}
+
+// object returns the merge patch of the deltas of an object.
+func (m *mergePatch) object(path diff.Path, deltas []diff.Delta, left map[string]interface{}, right map[string]interface{}) map[string]interface{} {
+	patch := map[string]interface{}{}
+	for _, delta := range deltas {
+		var name string
+		switch d := delta.(type) {
+		case diff.PostDelta:
+			name = d.PostPosition().String()
+		case diff.PreDelta:
+			name = d.PrePosition().String()
+		}
+		memberPath := childPath(path, diff.Name(name))
+		switch d := delta.(type) {
+		case *diff.Object:
+			patch[name] = m.object(memberPath, d.Deltas, left[name].(map[string]interface{}), right[name].(map[string]interface{}))
+		case *diff.Deleted:
+			patch[name] = nil
+		case *diff.Moved:
+			// a renamed member is deleted and added with its new name
+			patch[d.PrePosition().String()] = nil
+			patch[name] = m.replace(memberPath, nil, right[name])
+		default:
+			patch[name] = m.replace(memberPath, left[name], right[name])
+		}
+	}
+	return patch
+}
+
+// replace returns the merge patch of a value replaced by another one.
+func (m *mergePatch) replace(path diff.Path, left interface{}, right interface{}) interface{} {
+	rightObject, ok := right.(map[string]interface{})
+	if !ok {
+		if right == nil {
+			m.lose(path, "set to null, which removes the member")
+		}
+		return right
+	}
+	leftObject, ok := left.(map[string]interface{})
+	if !ok {
+		// the object is merged into an empty object
+		m.nulls(path, rightObject)
+		return right
+	}
+
+	patch := map[string]interface{}{}
+	for name := range leftObject {
+		if _, ok := rightObject[name]; !ok {
+			patch[name] = nil
+		}
+	}
+	for name, value := range rightObject {
+		if old, ok := leftObject[name]; !ok || !reflect.DeepEqual(old, value) {
+			patch[name] = m.replace(childPath(path, diff.Name(name)), old, value)
+		}
+	}
+	return patch
+}
+
+// nulls records the null members of an object added by a merge patch.
+func (m *mergePatch) nulls(path diff.Path, object map[string]interface{}) {
+	for name, value := range object {
+		memberPath := childPath(path, diff.Name(name))
+		switch typed := value.(type) {
+		case nil:
+			m.lose(memberPath, "set to null, which removes the member")
+		case map[string]interface{}:
+			m.nulls(memberPath, typed)
+		}
+	}
+}
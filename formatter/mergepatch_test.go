@@ -0,0 +1,89 @@
+package formatter_test
+
+import (
+	. "github.com/mrutkows/go-jsondiff/formatter"
+
+	. "github.com/mrutkows/go-jsondiff/tests"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	diff "github.com/mrutkows/go-jsondiff"
+)
+
+var _ = Describe("MergePatch", func() {
+	Describe("Format", func() {
+		It("Patches every fixture", func() {
+			fixtures := [][2]string{
+				{"base", "base_changed"},
+				{"add_delete_from", "add_delete_to"},
+				{"changed_types_from", "changed_types_to"},
+				{"long_text_from", "long_text_to"},
+				{"move_from", "move_to"},
+				{"keyed_from", "keyed_to"},
+			}
+			for _, fixture := range fixtures {
+				a := LoadFixture("../FIXTURES/" + fixture[0] + ".json")
+				b := LoadFixture("../FIXTURES/" + fixture[1] + ".json")
+				d := diff.New().CompareObjects(a, b)
+
+				patch, err := NewMergePatchFormatter(a).MergePatch(d)
+				Expect(err).To(BeNil(), fixture[0])
+				Expect(diff.New().ApplyMergePatch(a, patch)).To(Equal(b), fixture[0])
+			}
+		})
+
+		It("Replaces arrays and reports null values", func() {
+			a := map[string]interface{}{
+				"a": 1.0,
+				"b": []interface{}{1.0, 2.0},
+				"c": map[string]interface{}{"d": 1.0, "e": 2.0},
+				"f": "x",
+				"j": []interface{}{},
+			}
+			b := map[string]interface{}{
+				"a": 2.0,
+				"b": []interface{}{1.0, nil},
+				"c": map[string]interface{}{"d": 1.0},
+				"g": map[string]interface{}{"h": nil, "k": map[string]interface{}{"l": nil}},
+				"i": nil,
+				"j": map[string]interface{}{"m": 1.0},
+			}
+			d := diff.New().CompareObjects(a, b)
+
+			f := NewMergePatchFormatter(a)
+			f.PrintIndent = false
+			result, err := f.Format(d)
+			Expect(result).To(Equal(`{"a":2,"b":[1,null],"c":{"e":null},"f":null,"g":{"h":null,"k":{"l":null}},"i":null,"j":{"m":1}}` + "\n"))
+			Expect(err).To(Equal(&MergePatchError{Losses: []MergePatchLoss{
+				{Path: "/g/h", Reason: "set to null, which removes the member"},
+				{Path: "/g/k/l", Reason: "set to null, which removes the member"},
+				{Path: "/i", Reason: "set to null, which removes the member"},
+			}}))
+		})
+
+		It("Replaces documents which are not objects", func() {
+			a := []interface{}{1.0, 2.0}
+			b := []interface{}{2.0}
+			patch, err := NewMergePatchFormatter(a).MergePatch(diff.New().CompareArrays(a, b))
+			Expect(err).To(BeNil())
+			Expect(patch).To(Equal(b))
+
+			patch, err = NewMergePatchFormatter(a).MergePatch(diff.New().CompareValues(a, nil))
+			Expect(err).To(BeNil())
+			Expect(patch).To(BeNil())
+
+			c := map[string]interface{}{"a": nil, "b": 1.0}
+			patch, err = NewMergePatchFormatter(a).MergePatch(diff.New().CompareValues(a, c))
+			Expect(patch).To(Equal(c))
+			Expect(err).To(Equal(&MergePatchError{Losses: []MergePatchLoss{
+				{Path: "/a", Reason: "set to null, which removes the member"},
+			}}))
+		})
+
+		It("Fails on a Diff of another document", func() {
+			d := diff.New().CompareObjects(map[string]interface{}{"a": 1.0}, map[string]interface{}{"a": 2.0})
+			_, err := NewMergePatchFormatter(map[string]interface{}{"a": 3.0}).Format(d)
+			Expect(err).To(BeAssignableToTypeOf(&diff.PatchError{}))
+		})
+	})
+})
@@ -5,7 +5,9 @@ package gojsondiff
 
 import (
 	"container/list"
+	"context"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"sort"
 
@@ -18,10 +20,30 @@ type Diff interface {
 	Deltas() []Delta
 	// Modified returns true if Diff has at least one Delta.
 	Modified() bool
+	// Ignored returns JSON Pointers to the values whose changes were ignored.
+	// The list is only populated by a Differ with the ReportIgnored option.
+	Ignored() []string
+	// Reverse returns the Diff which undoes this Diff.
+	Reverse() Diff
+	// Strict returns the Diff which also holds the deltas hidden by the
+	// NullAsMissing and EmptyAsMissing options, so that it turns the left
+	// side into exactly the right side.
+	Strict() Diff
+	// Changes returns the changes of the deltas with their paths, without
+	// the Object and Array deltas holding them.
+	Changes() []Change
+	// Filter returns the Diff holding the changes for which the predicate
+	// returns true, which still applies to the left side.
+	Filter(predicate func(change Change) bool) Diff
+	// Select returns the Diff holding the changes of the values at the paths
+	// matched by a JSON Pointer pattern and of their descendants.
+	Select(pattern string) (Diff, error)
 }
 
 type diff struct {
-	deltas []Delta
+	deltas  []Delta
+	ignored []string
+	strict  *diff // nil when no delta is hidden
 }
 
 func (diff *diff) Deltas() []Delta {
@@ -32,34 +54,82 @@ func (diff *diff) Modified() bool {
 	return len(diff.deltas) > 0
 }
 
+func (diff *diff) Ignored() []string {
+	return diff.ignored
+}
+
 // A Differ compares JSON objects and applies patches
 type Differ struct {
 	textDiffMinimumLength int
+	arrayHashers          []arrayHasherRule
+	unorderedArrays       []pathPattern
+	allArraysUnordered    bool
+	ignored               []pathPattern
+	reportIgnored         bool
+	tolerances            []toleranceRule
+	useNumber             bool
+	maxLcsCells           int
+	maxSimilarityCells    int
+	maxDepth              int
+	failOnBudget          bool
+	newLcs                func(left, right []interface{}) Lcs
+	renameSimilarity      float64
+	moveSimilarity        float64
+	comparators           []comparatorRule
+	normalizers           []normalizerRule
+	recordNormalized      bool
+	nullAsMissing         []equivalenceRule
+	emptyAsMissing        []equivalenceRule
+	coerceTypes           []equivalenceRule
+}
+
+// A comparison holds the state of a single comparison made by a Differ.
+type comparison struct {
+	*Differ
+	ctx     context.Context
+	canFail bool  // the caller can report err, e.g. a BudgetError
+	err     error // the first error, which stops the comparison
+	ignored []string
+	// normalizedShapes is true when the objects and arrays compared are
+	// normalized already, e.g. those of a document being patched
+	normalizedShapes bool
+}
+
+func (differ *Differ) newComparison(ctx context.Context, canFail bool) *comparison {
+	return &comparison{Differ: differ, ctx: ctx, canFail: canFail}
 }
 
-// New returns new Differ with default configuration
-func New() *Differ {
-	return &Differ{
+// New returns new Differ with default configuration modified by the options
+func New(options ...Option) *Differ {
+	differ := &Differ{
 		textDiffMinimumLength: 30,
 	}
+	for _, option := range options {
+		option(differ)
+	}
+	return differ
 }
 
 // Compare compares two JSON strings as []bytes and return a Diff object.
+// The documents can have any JSON value at their root.
 func (differ *Differ) Compare(
 	left []byte,
 	right []byte,
 ) (Diff, error) {
-	var leftMap, rightMap map[string]interface{}
-	err := json.Unmarshal(left, &leftMap)
-	if err != nil {
-		return nil, err
-	}
+	return differ.CompareContext(context.Background(), left, right)
+}
 
-	err = json.Unmarshal(right, &rightMap)
-	if err != nil {
-		return nil, err
-	}
-	return differ.CompareObjects(leftMap, rightMap), nil
+// CompareValues compares two JSON documents of any type as interface{}
+// and return a Diff object. Two objects or two arrays are compared like
+// CompareObjects and CompareArrays do, other documents which differ produce
+// a single Delta at the Root position.
+func (differ *Differ) CompareValues(
+	left interface{},
+	right interface{},
+) Diff {
+	c := differ.newComparison(context.Background(), false)
+	deltas := c.compareRoots(left, right)
+	return c.newDiff(deltas)
 }
 
 // CompareObjects compares two JSON object as map[string]interface{}
@@ -68,8 +138,9 @@ func (differ *Differ) CompareObjects(
 	left map[string]interface{},
 	right map[string]interface{},
 ) Diff {
-	deltas := differ.compareMaps(left, right)
-	return &diff{deltas: deltas}
+	c := differ.newComparison(context.Background(), false)
+	deltas := c.compareRoots(left, right)
+	return c.newDiff(deltas)
 }
 
 // CompareArrays compares two JSON arrays as []interface{}
@@ -78,11 +149,35 @@ func (differ *Differ) CompareArrays(
 	left []interface{},
 	right []interface{},
 ) Diff {
-	deltas := differ.compareArrays(left, right)
-	return &diff{deltas: deltas}
+	c := differ.newComparison(context.Background(), false)
+	deltas := c.compareRoots(left, right)
+	return c.newDiff(deltas)
 }
 
-func (differ *Differ) compareMaps(
+func (c *comparison) compareRoots(
+	left interface{},
+	right interface{},
+) (deltas []Delta) {
+	switch l := c.normalize(Path{}, left).(type) {
+	case map[string]interface{}:
+		if r, ok := c.normalize(Path{}, right).(map[string]interface{}); ok {
+			return c.compareMaps(Path{}, l, r)
+		}
+	case []interface{}:
+		if r, ok := c.normalize(Path{}, right).([]interface{}); ok {
+			return c.compareArrays(Path{}, l, r)
+		}
+	}
+
+	deltas = make([]Delta, 0)
+	if same, delta := c.compareValues(Path{}, left, right); !same {
+		deltas = append(deltas, delta)
+	}
+	return deltas
+}
+
+func (c *comparison) compareMaps(
+	path Path,
 	left map[string]interface{},
 	right map[string]interface{},
 ) (deltas []Delta) {
@@ -90,44 +185,220 @@ func (differ *Differ) compareMaps(
 
 	names := sortedKeys(left) // stabilize delta order
 	for _, name := range names {
-		if rightValue, ok := right[name]; ok {
-			same, delta := differ.compareValues(Name(name), left[name], rightValue)
+		rightValue, ok := right[name]
+		if c.skipIgnored(path.child(Name(name)), left[name], true, rightValue, ok) {
+			continue
+		}
+		if ok {
+			same, delta := c.compareValues(path.child(Name(name)), left[name], rightValue)
 			if !same {
 				deltas = append(deltas, delta)
 			}
 		} else {
-			deltas = append(deltas, NewDeleted(Name(name), left[name]))
+			deltas = append(deltas, NewDeleted(Name(name), c.recorded(path.child(Name(name)), left[name])))
 		}
 	}
 
 	names = sortedKeys(right) // stabilize delta order
 	for _, name := range names {
 		if _, ok := left[name]; !ok {
-			deltas = append(deltas, NewAdded(Name(name), right[name]))
+			if c.skipIgnored(path.child(Name(name)), nil, false, right[name], true) {
+				continue
+			}
+			deltas = append(deltas, NewAdded(Name(name), c.recorded(path.child(Name(name)), right[name])))
 		}
 	}
 
+	if c.renameSimilarity > 0 {
+		deltas = c.detectRenames(path, deltas)
+	}
 	return deltas
 }
 
-// ApplyPatch applies a Diff to an JSON object. This method is destructive.
-func (differ *Differ) ApplyPatch(json map[string]interface{}, patch Diff) {
-	applyDeltas(patch.Deltas(), json)
+// ApplyPatch applies a Diff to a JSON document of any type and returns the
+// patched document. This method is destructive: objects are patched in place,
+// but the patched document must be taken from the returned value as arrays
+// may be reallocated and Root deltas replace the whole document.
+// The objects and arrays of the document are normalized by the normalizers of
+// the Differ first, as the positions of the deltas refer to the normalized
+// documents, so that the patched document is normalized too, e.g. with sorted
+// arrays, while its other values are left as they are.
+func (differ *Differ) ApplyPatch(json interface{}, patch Diff) interface{} {
+	return applyDeltas(patch.Deltas(), differ.normalizeShape(Path{}, json))
 }
 
 type maybe struct {
 	index    int
 	lcsIndex int
 	item     interface{}
+	token    interface{}
+}
+
+// An arrayKey is the identity of an array item computed by an ArrayHasher.
+type arrayKey struct {
+	hash string
+}
+
+// arrayTokens returns the values used to match the items of an array:
+// the identities of the items which have one, the normalized items without
+// their ignored members if any and the items themselves otherwise.
+// tokenized is false when the tokens are the items themselves.
+func (c *comparison) arrayTokens(path Path, items []interface{}) (tokens []interface{}, tokenized bool) {
+	hasher := c.arrayHasher(path)
+	if hasher == nil && !c.ignoresBelow(path) && !c.normalizesBelow(path) {
+		return items, false
+	}
+	tokens = make([]interface{}, len(items))
+	for i, item := range items {
+		itemPath := path.child(Index(i))
+		if hasher != nil {
+			if hash, ok := hasher(c.normalize(itemPath, item)); ok {
+				tokens[i] = arrayKey{hash: hash}
+				continue
+			}
+		}
+		tokens[i] = c.withoutIgnored(itemPath, c.normalizeDeep(itemPath, item))
+	}
+	return tokens, true
 }
 
-func (differ *Differ) compareArrays(
+// looseTokens returns the tokens of the right items, where the token of each
+// item which is not deeply equal to a left item but the same for
+// compareValues, e.g. with a comparator or a tolerance, is replaced by the
+// token of that left item, so that the items are matched. replaced is false
+// when no token is replaced. The pairs of items compared are limited by
+// MaxSimilarityCells.
+func (c *comparison) looseTokens(
+	path Path,
+	left []interface{},
+	right []interface{},
+	leftTokens []interface{},
+	rightTokens []interface{},
+) (tokens []interface{}, replaced bool) {
+	if len(c.comparators) == 0 && len(c.tolerances) == 0 && len(c.coerceTypes) == 0 && !c.useNumber {
+		return rightTokens, false
+	}
+
+	// pair the deeply equal items first, the remaining ones are compared
+	buckets := make(map[string][]int, len(leftTokens))
+	for i, token := range leftTokens {
+		bucket := fmt.Sprintf("%#v", token)
+		buckets[bucket] = append(buckets[bucket], i)
+	}
+	unmatched := make([]int, 0)
+	for j, token := range rightTokens {
+		bucket := fmt.Sprintf("%#v", token)
+		matched := false
+		for k, i := range buckets[bucket] {
+			if reflect.DeepEqual(leftTokens[i], token) {
+				buckets[bucket] = append(buckets[bucket][:k:k], buckets[bucket][k+1:]...)
+				matched = true
+				break
+			}
+		}
+		if _, keyed := token.(arrayKey); !matched && !keyed {
+			unmatched = append(unmatched, j)
+		}
+	}
+	remaining := make([]int, 0)
+	for _, indexes := range buckets {
+		for _, i := range indexes {
+			if _, keyed := leftTokens[i].(arrayKey); !keyed {
+				remaining = append(remaining, i)
+			}
+		}
+	}
+	if len(unmatched) == 0 || len(remaining) == 0 {
+		return rightTokens, false
+	}
+	// too many pairs to compare, the items are matched by their tokens only
+	if c.exceeded(BudgetSimilarityCells, path, c.maxSimilarityCells, len(unmatched)*len(remaining)) {
+		return rightTokens, false
+	}
+	sort.Ints(remaining)
+
+	tokens = rightTokens
+	for _, j := range unmatched {
+		for k, i := range remaining {
+			if c.stopped() {
+				return tokens, replaced
+			}
+			if !c.sameItems(path.child(Index(j)), left[i], right[j]) {
+				continue
+			}
+			if !replaced {
+				// the tokens may be the right items themselves
+				tokens = append([]interface{}{}, rightTokens...)
+				replaced = true
+			}
+			tokens[j] = leftTokens[i]
+			remaining = append(remaining[:k:k], remaining[k+1:]...)
+			break
+		}
+	}
+	return tokens, replaced
+}
+
+// sameItems returns true if compareValues finds the items the same, without
+// reporting the ignored values which differ.
+func (c *comparison) sameItems(path Path, left interface{}, right interface{}) bool {
+	ignored := len(c.ignored)
+	same, _ := c.compareValues(path, left, right)
+	c.ignored = c.ignored[:ignored]
+	return same
+}
+
+// separateKeyed splits items into those without and those with an identity.
+// Items with different identities are never paired as modified.
+func separateKeyed(items []maybe) (unkeyed []maybe, keyed []maybe) {
+	unkeyed = make([]maybe, 0, len(items))
+	for _, item := range items {
+		if _, ok := item.token.(arrayKey); ok {
+			keyed = append(keyed, item)
+		} else {
+			unkeyed = append(unkeyed, item)
+		}
+	}
+	return unkeyed, keyed
+}
+
+func (c *comparison) compareArrays(
+	path Path,
 	left []interface{},
 	right []interface{},
 ) (deltas []Delta) {
 	deltas = make([]Delta, 0)
+	leftTokens, tokenized := c.arrayTokens(path, left)
+	rightTokens, _ := c.arrayTokens(path, right)
+	// items matched by a comparator or a tolerance have their contents compared
+	if tokens, replaced := c.looseTokens(path, left, right, leftTokens, rightTokens); replaced {
+		rightTokens, tokenized = tokens, true
+	}
+
+	if c.isUnordered(path) {
+		return c.compareUnorderedArrays(path, left, right, leftTokens, rightTokens, tokenized)
+	}
+
 	// LCS index pairs
-	lcsPairs := NewLCS(left, right).IndexPairs()
+	lcsPairs, positional := c.budgetedLcsPairs(path, leftTokens, rightTokens)
+	if c.stopped() {
+		return deltas
+	}
+
+	// items matched by their tokens may still differ in their contents
+	if tokenized {
+		for _, lcsPair := range lcsPairs {
+			same, delta := c.compareValues(path.child(Index(lcsPair.Right)), left[lcsPair.Left], right[lcsPair.Right])
+			if !same {
+				deltas = append(deltas, delta)
+			}
+		}
+	}
+
+	// arrays too large for the LCS are compared position by position
+	if positional {
+		return append(deltas, c.comparePositionally(path, left, right, lcsPairs)...)
+	}
 
 	// list up items not in LCS, they are maybe deleted
 	maybeDeleted := list.New() // but maybe moved or modified
@@ -136,7 +407,7 @@ func (differ *Differ) compareArrays(
 		if lcsI < len(lcsPairs) && lcsPairs[lcsI].Left == i {
 			lcsI++
 		} else {
-			maybeDeleted.PushBack(maybe{index: i, lcsIndex: lcsI, item: leftValue})
+			maybeDeleted.PushBack(maybe{index: i, lcsIndex: lcsI, item: leftValue, token: leftTokens[i]})
 		}
 	}
 
@@ -147,7 +418,7 @@ func (differ *Differ) compareArrays(
 		if lcsI < len(lcsPairs) && lcsPairs[lcsI].Right == i {
 			lcsI++
 		} else {
-			maybeAdded.PushBack(maybe{index: i, lcsIndex: lcsI, item: rightValue})
+			maybeAdded.PushBack(maybe{index: i, lcsIndex: lcsI, item: rightValue, token: rightTokens[i]})
 		}
 	}
 
@@ -159,8 +430,15 @@ func (differ *Differ) compareArrays(
 
 		for addCandidate := maybeAdded.Front(); addCandidate != nil; addCandidate = addCandidate.Next() {
 			addCan := addCandidate.Value.(maybe)
-			if reflect.DeepEqual(delCan.item, addCan.item) {
-				deltas = append(deltas, NewMoved(Index(delCan.index), Index(addCan.index), delCan.item, nil))
+			if reflect.DeepEqual(delCan.token, addCan.token) {
+				// a moved item matched by its token carries the changes of its contents
+				var delta Delta
+				if tokenized {
+					if same, itemDelta := c.compareValues(path.child(Index(addCan.index)), delCan.item, addCan.item); !same {
+						delta = itemDelta
+					}
+				}
+				deltas = append(deltas, NewMoved(Index(delCan.index), Index(addCan.index), c.recorded(path.child(Index(delCan.index)), delCan.item), delta))
 				maybeAdded.Remove(addCandidate)
 				maybeDeleted.Remove(delCandidate)
 				break
@@ -168,6 +446,11 @@ func (differ *Differ) compareArrays(
 		}
 	}
 
+	// find moved items whose contents changed
+	if c.moveSimilarity > 0 {
+		deltas = append(deltas, c.detectMoves(path, maybeDeleted, maybeAdded)...)
+	}
+
 	// find modified or add+del
 	prevIndexDel := 0
 	prevIndexAdd := 0
@@ -212,55 +495,179 @@ func (differ *Differ) compareArrays(
 			addSlice = append(addSlice, a)
 		}
 
+		var delKeyed, addKeyed []maybe
+		delSlice, delKeyed = separateKeyed(delSlice)
+		addSlice, addKeyed = separateKeyed(addSlice)
+
 		if len(delSlice) > 0 && len(addSlice) > 0 {
 			var bestDeltas []Delta
-			bestDeltas, delSlice, addSlice = differ.maximizeSimilarities(delSlice, addSlice)
+			bestDeltas, delSlice, addSlice = c.maximizeSimilarities(path, delSlice, addSlice)
 			deltas = append(deltas, bestDeltas...)
 		}
+		delSlice = append(delSlice, delKeyed...)
+		addSlice = append(addSlice, addKeyed...)
 
 		for _, del := range delSlice {
-			deltas = append(deltas, NewDeleted(Index(del.index), del.item))
+			deltas = append(deltas, NewDeleted(Index(del.index), c.recorded(path.child(Index(del.index)), del.item)))
 		}
 		for _, add := range addSlice {
-			deltas = append(deltas, NewAdded(Index(add.index), add.item))
+			deltas = append(deltas, NewAdded(Index(add.index), c.recorded(path.child(Index(add.index)), add.item)))
 		}
 	}
 
 	return deltas
 }
 
-func (differ *Differ) compareValues(
-	position Position,
+// compareUnorderedArrays compares two arrays as multisets.
+// Positions of the deltas refer to the patched array, which keeps the left
+// side items in their order and has the added items appended.
+func (c *comparison) compareUnorderedArrays(
+	path Path,
+	left []interface{},
+	right []interface{},
+	leftTokens []interface{},
+	rightTokens []interface{},
+	tokenized bool,
+) (deltas []Delta) {
+	deltas = make([]Delta, 0)
+
+	// bucket left items by their token to find equal items quickly
+	buckets := make(map[string][]int, len(left))
+	for i, token := range leftTokens {
+		bucket := fmt.Sprintf("%#v", token)
+		buckets[bucket] = append(buckets[bucket], i)
+	}
+
+	matches := make([]int, len(left)) // index in right + 1, 0 if unmatched
+	added := make([]int, 0)
+	for j, token := range rightTokens {
+		bucket := fmt.Sprintf("%#v", token)
+		candidates := buckets[bucket]
+		matched := false
+		for k, i := range candidates {
+			if reflect.DeepEqual(leftTokens[i], token) {
+				matches[i] = j + 1
+				buckets[bucket] = append(candidates[:k:k], candidates[k+1:]...)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			added = append(added, j)
+		}
+	}
+
+	kept := 0
+	for i, match := range matches {
+		if match == 0 {
+			deltas = append(deltas, NewDeleted(Index(i), c.recorded(path.child(Index(i)), left[i])))
+			continue
+		}
+		// items matched by their tokens may still differ in their contents
+		if tokenized {
+			same, delta := c.compareValues(path.child(Index(kept)), left[i], right[match-1])
+			if !same {
+				deltas = append(deltas, delta)
+			}
+		}
+		kept++
+	}
+
+	for k, j := range added {
+		deltas = append(deltas, NewAdded(Index(kept+k), c.recorded(path.child(Index(j)), right[j])))
+	}
+
+	return deltas
+}
+
+func (c *comparison) compareValues(
+	path Path,
+	left interface{},
+	right interface{},
+) (same bool, delta Delta) {
+	if c.stopped() {
+		return true, nil
+	}
+	if len(c.normalizers) == 0 {
+		return c.compareNormalized(path, left, right)
+	}
+	same, delta = c.compareNormalized(path, c.normalize(path, left), c.normalize(path, right))
+	if !same {
+		delta = c.recordValues(path, delta, left, right)
+	}
+	return same, delta
+}
+
+// compareNormalized compares two values normalized by the normalizers of the
+// values at the path with their comparators first.
+func (c *comparison) compareNormalized(
+	path Path,
 	left interface{},
 	right interface{},
 ) (same bool, delta Delta) {
+	if len(c.comparators) > 0 {
+		switch verdict, similarity := c.customCompare(path, left, right); verdict {
+		case VerdictEqual:
+			return true, nil
+		case VerdictNotEqual:
+			if same, delta := c.compareDefault(path, left, right); !same {
+				return false, withSimilarity(delta, similarity)
+			}
+			return false, withSimilarity(NewModified(path.position(), left, right), similarity)
+		}
+	}
+	return c.compareDefault(path, left, right)
+}
+
+// compareDefault compares two values without the comparators of the values
+// at the path, the values of their children are compared by compareValues.
+func (c *comparison) compareDefault(
+	path Path,
+	left interface{},
+	right interface{},
+) (same bool, delta Delta) {
+	position := path.position()
 	if reflect.TypeOf(left) != reflect.TypeOf(right) {
+		if len(c.coerceTypes) > 0 && c.typeChanged(path, left, right) {
+			return false, NewTypeChanged(position, left, right)
+		}
 		return false, NewModified(position, left, right)
 	}
 
 	switch left.(type) {
 
 	case map[string]interface{}:
+		if c.tooDeep(path) {
+			return c.compareWhole(path, left, right)
+		}
 		l := left.(map[string]interface{})
-		childDeltas := differ.compareMaps(l, right.(map[string]interface{}))
+		childDeltas := c.compareMaps(path, l, right.(map[string]interface{}))
 		if len(childDeltas) > 0 {
 			return false, NewObject(position, childDeltas)
 		}
 
 	case []interface{}:
+		if c.tooDeep(path) {
+			return c.compareWhole(path, left, right)
+		}
 		l := left.([]interface{})
-		childDeltas := differ.compareArrays(l, right.([]interface{}))
+		childDeltas := c.compareArrays(path, l, right.([]interface{}))
 
 		if len(childDeltas) > 0 {
 			return false, NewArray(position, childDeltas)
 		}
 
+	case float64, json.Number:
+		if !c.equalNumbers(path, left, right) {
+			return false, NewModified(position, left, right)
+		}
+
 	default:
 		if !reflect.DeepEqual(left, right) {
 
 			if reflect.ValueOf(left).Kind() == reflect.String &&
 				reflect.ValueOf(right).Kind() == reflect.String &&
-				differ.textDiffMinimumLength <= len(left.(string)) {
+				c.textDiffMinimumLength <= len(left.(string)) {
 
 				textDiff := dmp.New()
 				patches := textDiff.PatchMake(left.(string), right.(string))
@@ -304,15 +711,26 @@ func applyDeltas(deltas []Delta, object interface{}) interface{} {
 	return object
 }
 
-func (differ *Differ) maximizeSimilarities(left []maybe, right []maybe) (resultDeltas []Delta, freeLeft, freeRight []maybe) {
+func (c *comparison) maximizeSimilarities(path Path, left []maybe, right []maybe) (resultDeltas []Delta, freeLeft, freeRight []maybe) {
+	// too many pairs to compare, the items are reported as deleted and added
+	if c.exceeded(BudgetSimilarityCells, path, c.maxSimilarityCells, len(left)*len(right)) {
+		return nil, left, right
+	}
+
+	// the ignored values which differ are only reported for the kept pairs
 	deltaTable := make([][]Delta, len(left))
+	ignoredTable := make([][][]string, len(left))
 	for i := 0; i < len(left); i++ {
 		deltaTable[i] = make([]Delta, len(right))
+		ignoredTable[i] = make([][]string, len(right))
 	}
+	ignored := len(c.ignored)
 	for i, leftValue := range left {
 		for j, rightValue := range right {
-			_, delta := differ.compareValues(Index(rightValue.index), leftValue.item, rightValue.item)
+			_, delta := c.compareValues(path.child(Index(rightValue.index)), leftValue.item, rightValue.item)
 			deltaTable[i][j] = delta
+			ignoredTable[i][j] = append([]string(nil), c.ignored[ignored:]...)
+			c.ignored = c.ignored[:ignored]
 		}
 	}
 
@@ -325,10 +743,13 @@ func (differ *Differ) maximizeSimilarities(left []maybe, right []maybe) (resultD
 		dpTable[i] = make([]float64, sizeY)
 	}
 	for x := sizeX - 2; x >= 0; x-- {
+		if c.stopped() {
+			return nil, left, right
+		}
 		for y := sizeY - 2; y >= 0; y-- {
 			prevX := dpTable[x+1][y]
 			prevY := dpTable[x][y+1]
-			score := deltaTable[x][y].Similarity() + dpTable[x+1][y+1]
+			score := similarityOf(deltaTable[x][y]) + dpTable[x+1][y+1]
 
 			//dpTable[x][y] = maxFloat64(prevX, prevY, score)
 			dpTable[x][y] = maxVariadic(prevX, prevY, score)
@@ -361,21 +782,88 @@ func (differ *Differ) maximizeSimilarities(left []maybe, right []maybe) (resultD
 			freeRight = append(freeRight, right[y])
 			y++
 		} else {
-			resultDeltas = append(resultDeltas, deltaTable[x][y])
+			// items compared as the same produce no delta
+			if deltaTable[x][y] != nil {
+				resultDeltas = append(resultDeltas, deltaTable[x][y])
+			}
+			c.ignored = append(c.ignored, ignoredTable[x][y]...)
 			x++
 			y++
 		}
 	}
 	for ; x < sizeX-1; x++ {
-		freeLeft = append(freeLeft, left[x-1])
+		freeLeft = append(freeLeft, left[x])
 	}
 	for ; y < sizeY-1; y++ {
-		freeRight = append(freeRight, right[y-1])
+		freeRight = append(freeRight, right[y])
 	}
 
 	return resultDeltas, freeLeft, freeRight
 }
 
+// similarityOf returns the similarity of a delta returned by compareValues,
+// a nil delta means the values are the same.
+func similarityOf(delta Delta) float64 {
+	if delta == nil {
+		return 1
+	}
+	return delta.Similarity()
+}
+
+// A similarPair is a deleted and an added value which are paired as the same
+// value, e.g. a moved array item or a renamed member.
+type similarPair struct {
+	deleted    int // index of the deleted value
+	added      int // index of the added value
+	delta      Delta
+	similarity float64
+	// ignored holds the paths of the ignored values which differ
+	ignored []string
+}
+
+// pairSimilarValues compares the deleted and the added values, given by the
+// values function which returns the path and the values of a pair or false
+// when they cannot be paired, and returns the pairs whose values have at least
+// the similarity. Each value is paired at most once, the most similar pairs
+// first. There is no pair when the comparison is stopped.
+func (c *comparison) pairSimilarValues(deletedCount, addedCount int, similarity float64, values func(deleted, added int) (Path, interface{}, interface{}, bool)) []similarPair {
+	// the ignored values which differ are only reported for the pairs
+	ignored := len(c.ignored)
+	candidates := make([]similarPair, 0)
+	for deleted := 0; deleted < deletedCount; deleted++ {
+		for added := 0; added < addedCount; added++ {
+			path, deletedValue, addedValue, ok := values(deleted, added)
+			if !ok {
+				continue
+			}
+			if c.stopped() {
+				return nil
+			}
+			_, delta := c.compareValues(path, deletedValue, addedValue)
+			if candidateSimilarity := similarityOf(delta); candidateSimilarity >= similarity {
+				candidates = append(candidates, similarPair{deleted: deleted, added: added, delta: delta, similarity: candidateSimilarity,
+					ignored: append([]string(nil), c.ignored[ignored:]...)})
+			}
+			c.ignored = c.ignored[:ignored]
+		}
+	}
+	// the candidates are in the order of the values, the most similar ones are
+	// paired first
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].similarity > candidates[j].similarity })
+
+	pairs := make([]similarPair, 0)
+	pairedDeleted, pairedAdded := make(map[int]bool), make(map[int]bool)
+	for _, candidate := range candidates {
+		if pairedDeleted[candidate.deleted] || pairedAdded[candidate.added] {
+			continue
+		}
+		pairedDeleted[candidate.deleted], pairedAdded[candidate.added] = true, true
+		pairs = append(pairs, candidate)
+		c.ignored = append(c.ignored, candidate.ignored...)
+	}
+	return pairs
+}
+
 func deltasSimilarity(deltas []Delta) (similarity float64) {
 	for _, delta := range deltas {
 		similarity += delta.Similarity()
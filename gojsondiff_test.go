@@ -2,12 +2,20 @@ package gojsondiff_test
 
 import (
 	. "github.com/mrutkows/go-jsondiff"
+	"github.com/mrutkows/go-jsondiff/formatter"
 
 	. "github.com/mrutkows/go-jsondiff/tests"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"math/rand"
+	"strings"
+	"time"
 )
 
 var _ = Describe("Gojsondiff", func() {
@@ -111,6 +119,2001 @@ var _ = Describe("Gojsondiff", func() {
 					Expect(len(diff.Deltas())).To(Equal(1))
 				})
 			})
+
+			Context("There are unpaired items after the last pair of modified items", func() {
+				It("Detects the unpaired items", func() {
+					a = []interface{}{
+						map[string]interface{}{"a": 1.0, "b": 1.0},
+						map[string]interface{}{"c": 2.0},
+						map[string]interface{}{"d": 3.0},
+					}
+					b = []interface{}{
+						map[string]interface{}{"a": 1.0, "b": 2.0},
+					}
+
+					diff := differ.CompareArrays(a, b)
+					Expect(diff.Deltas()).To(ConsistOf(
+						NewObject(Index(0), []Delta{NewModified(Name("b"), 1.0, 2.0)}),
+						NewDeleted(Index(1), map[string]interface{}{"c": 2.0}),
+						NewDeleted(Index(2), map[string]interface{}{"d": 3.0}),
+					))
+
+					diff = differ.CompareArrays(b, a)
+					Expect(diff.Deltas()).To(ConsistOf(
+						NewObject(Index(0), []Delta{NewModified(Name("b"), 2.0, 1.0)}),
+						NewAdded(Index(1), map[string]interface{}{"c": 2.0}),
+						NewAdded(Index(2), map[string]interface{}{"d": 3.0}),
+					))
+				})
+			})
+		})
+		Describe("ArrayKeys", func() {
+
+			var (
+				a, b   map[string]interface{}
+				differ *Differ
+			)
+
+			BeforeEach(func() {
+				a = LoadFixture("FIXTURES/keyed_from.json")
+				b = LoadFixture("FIXTURES/keyed_to.json")
+				differ = New(ArrayKeys("/countries", "name"))
+			})
+
+			Context("Array items are matched by a key", func() {
+				It("Detects nested changes and moves of matched items", func() {
+					diff := differ.CompareObjects(a, b)
+					Expect(diff.Modified()).To(BeTrue())
+					Expect(diff.Deltas()).To(HaveLen(1))
+
+					countries := diff.Deltas()[0].(*Array)
+					Expect(countries.Deltas).To(HaveLen(4))
+					for _, delta := range countries.Deltas {
+						switch d := delta.(type) {
+						case *Object:
+							Expect(d.Position).To(Equal(Index(0)))
+							Expect(d.Deltas).To(Equal([]Delta{NewModified(Name("capital"), "Buenos Aires", "Rawson")}))
+						case *Deleted:
+							Expect(d.PrePosition()).To(Equal(Index(1)))
+						case *Moved:
+							Expect(d.PrePosition()).To(Equal(Index(4)))
+							Expect(d.PostPosition()).To(Equal(Index(1)))
+							Expect(d.Delta).To(Equal(NewObject(Index(1), []Delta{NewModified(Name("unasur"), true, false)})))
+						case *Added:
+							Expect(d.PostPosition()).To(Equal(Index(4)))
+						default:
+							Fail("unexpected delta")
+						}
+					}
+
+					differ.ApplyPatch(a, diff)
+					Expect(a).To(Equal(LoadFixture("FIXTURES/keyed_to.json")))
+				})
+			})
+
+			Context("Array items are matched by a composite key", func() {
+				It("Treats items with different keys as different items", func() {
+					differ = New(ArrayKeys("/countries", "name", "capital"))
+					diff := differ.CompareObjects(a, b)
+
+					countries := diff.Deltas()[0].(*Array)
+					for _, delta := range countries.Deltas {
+						if d, ok := delta.(*Object); ok {
+							Expect(d.Deltas).NotTo(ContainElement(BeAssignableToTypeOf(&Modified{})))
+						}
+					}
+
+					differ.ApplyPatch(a, diff)
+					Expect(a).To(Equal(LoadFixture("FIXTURES/keyed_to.json")))
+				})
+			})
+
+			Context("The path pattern has a wildcard", func() {
+				It("Matches the items of arrays at every matching path", func() {
+					a := map[string]interface{}{"regions": []interface{}{a}}
+					b := map[string]interface{}{"regions": []interface{}{b}}
+					differ = New(ArrayKeys("/regions/*/countries", "name"))
+
+					diff := differ.CompareObjects(a, b)
+					differ.ApplyPatch(a, diff)
+					Expect(a["regions"]).To(Equal([]interface{}{LoadFixture("FIXTURES/keyed_to.json")}))
+				})
+			})
+		})
+		Describe("UnorderedArrays", func() {
+
+			var (
+				a, b map[string]interface{}
+			)
+
+			BeforeEach(func() {
+				a = map[string]interface{}{
+					"tags":  []interface{}{"red", "green", "blue", "green"},
+					"order": []interface{}{1.0, 2.0},
+				}
+				b = map[string]interface{}{
+					"tags":  []interface{}{"blue", "yellow", "green", "red"},
+					"order": []interface{}{2.0, 1.0},
+				}
+			})
+
+			Context("All arrays are unordered", func() {
+				It("Reports only added and deleted items", func() {
+					differ := New(UnorderedArrays())
+					diff := differ.CompareObjects(a, b)
+					Expect(diff.Deltas()).To(Equal([]Delta{
+						NewArray(Name("tags"), []Delta{
+							NewDeleted(Index(3), "green"),
+							NewAdded(Index(3), "yellow"),
+						}),
+					}))
+
+					differ.ApplyPatch(a, diff)
+					Expect(a["tags"]).To(ConsistOf(b["tags"]))
+					Expect(a["order"]).To(ConsistOf(b["order"]))
+				})
+
+				It("Detects nothing when only the order changes", func() {
+					diff := New(UnorderedArrays()).CompareObjects(
+						map[string]interface{}{"tags": []interface{}{"a", "b", "c"}},
+						map[string]interface{}{"tags": []interface{}{"c", "a", "b"}},
+					)
+					Expect(diff.Modified()).To(BeFalse())
+				})
+			})
+
+			Context("Only some arrays are unordered", func() {
+				It("Compares other arrays by order", func() {
+					differ := New(UnorderedArrays("/tags"))
+					diff := differ.CompareObjects(a, b)
+					Expect(diff.Deltas()).To(HaveLen(2))
+
+					differ.ApplyPatch(a, diff)
+					Expect(a["tags"]).To(ConsistOf(b["tags"]))
+					Expect(a["order"]).To(Equal(b["order"]))
+				})
+			})
+
+			Context("Unordered array items are matched by a key", func() {
+				It("Detects nested changes at the patched index", func() {
+					a := LoadFixture("FIXTURES/keyed_from.json")
+					b := LoadFixture("FIXTURES/keyed_to.json")
+					differ := New(UnorderedArrays("/countries"), ArrayKeys("/countries", "name"))
+					diff := differ.CompareObjects(a, b)
+
+					countries := diff.Deltas()[0].(*Array)
+					Expect(countries.Deltas).To(ConsistOf(
+						NewObject(Index(0), []Delta{NewModified(Name("capital"), "Buenos Aires", "Rawson")}),
+						NewDeleted(Index(1), map[string]interface{}{"name": "Bolivia", "capital": "La Paz", "unasur": true}),
+						NewObject(Index(3), []Delta{NewModified(Name("unasur"), true, false)}),
+						NewAdded(Index(4), map[string]interface{}{"name": "Peru", "capital": "Lima", "unasur": true}),
+					))
+
+					differ.ApplyPatch(a, diff)
+					Expect(a["countries"]).To(ConsistOf(b["countries"]))
+				})
+			})
+		})
+		Describe("Ignore", func() {
+
+			var (
+				a, b map[string]interface{}
+			)
+
+			BeforeEach(func() {
+				a = map[string]interface{}{
+					"requestId": "a1",
+					"metadata":  map[string]interface{}{"generation": 1.0, "name": "left"},
+					"items": []interface{}{
+						map[string]interface{}{"id": 1.0, "updatedAt": "monday"},
+						map[string]interface{}{"id": 2.0, "updatedAt": "monday"},
+					},
+				}
+				b = map[string]interface{}{
+					"metadata": map[string]interface{}{"generation": 2.0, "name": "left"},
+					"items": []interface{}{
+						map[string]interface{}{"id": 1.0, "updatedAt": "tuesday"},
+						map[string]interface{}{"id": 2.0, "updatedAt": "tuesday"},
+					},
+				}
+			})
+
+			Context("All changes are at ignored paths", func() {
+				It("Detects nothing", func() {
+					diff := New(Ignore("/requestId", "/metadata/generation", "/items/*/updatedAt")).CompareObjects(a, b)
+					Expect(diff.Modified()).To(BeFalse())
+					Expect(diff.Ignored()).To(BeEmpty())
+				})
+
+				It("Reports the ignored paths", func() {
+					diff := New(Ignore("/**/requestId", "/metadata/generation", "/items/*/updatedAt"), ReportIgnored()).CompareObjects(a, b)
+					Expect(diff.Modified()).To(BeFalse())
+					Expect(diff.Ignored()).To(Equal([]string{
+						"/items/0/updatedAt",
+						"/items/1/updatedAt",
+						"/metadata/generation",
+						"/requestId",
+					}))
+				})
+			})
+
+			Context("Ignored values are in modified array items", func() {
+				It("Reports the ignored paths of the paired items only", func() {
+					a := map[string]interface{}{"a": []interface{}{
+						map[string]interface{}{"id": 1.0, "ts": 1.0},
+						map[string]interface{}{"id": 2.0, "ts": 1.0},
+					}}
+					b := map[string]interface{}{"a": []interface{}{
+						map[string]interface{}{"id": 3.0, "ts": 2.0},
+						map[string]interface{}{"id": 4.0, "ts": 2.0},
+					}}
+					diff := New(Ignore("/a/*/ts"), ReportIgnored()).CompareObjects(a, b)
+					Expect(diff.Deltas()).To(HaveLen(1))
+					Expect(diff.Ignored()).To(Equal([]string{"/a/0/ts", "/a/1/ts"}))
+				})
+			})
+
+			Context("Some changes are at ignored paths", func() {
+				It("Detects other changes", func() {
+					b["items"] = append(b["items"].([]interface{}), map[string]interface{}{"id": 3.0, "updatedAt": "tuesday"})
+					diff := New(Ignore("/items/*/updatedAt")).CompareObjects(a, b)
+					Expect(diff.Deltas()).To(ConsistOf(
+						NewDeleted(Name("requestId"), "a1"),
+						NewObject(Name("metadata"), []Delta{NewModified(Name("generation"), 1.0, 2.0)}),
+						NewArray(Name("items"), []Delta{
+							NewAdded(Index(2), map[string]interface{}{"id": 3.0, "updatedAt": "tuesday"}),
+						}),
+					))
+				})
+			})
+		})
+		Describe("Tolerance", func() {
+
+			var (
+				a, b map[string]interface{}
+			)
+
+			BeforeEach(func() {
+				sum := 0.1
+				sum += 0.2 // 0.30000000000000004
+				a = map[string]interface{}{
+					"sum":    sum,
+					"price":  100.0,
+					"values": []interface{}{1.0, 2.0, 3.0},
+				}
+				b = map[string]interface{}{
+					"sum":    0.3,
+					"price":  101.0,
+					"values": []interface{}{1.0, 2.0000000001, 3.0},
+				}
+			})
+
+			Context("Without tolerance", func() {
+				It("Detects changes of floating point results", func() {
+					diff := New().CompareObjects(a, b)
+					Expect(diff.Deltas()).To(HaveLen(3))
+				})
+			})
+
+			Context("With an absolute tolerance", func() {
+				It("Detects only changes above the tolerance", func() {
+					diff := New(Tolerance(1e-6, 0)).CompareObjects(a, b)
+					Expect(diff.Deltas()).To(Equal([]Delta{NewModified(Name("price"), 100.0, 101.0)}))
+				})
+			})
+
+			Context("With a relative tolerance for some paths", func() {
+				It("Detects changes at other paths", func() {
+					diff := New(Tolerance(0, 0.02, "/price")).CompareObjects(a, b)
+					Expect(diff.Deltas()).To(HaveLen(2))
+					Expect(diff.Deltas()).NotTo(ContainElement(NewModified(Name("price"), 100.0, 101.0)))
+				})
+			})
+
+			Context("With an absolute tolerance for moved array items", func() {
+				It("Matches the items within the tolerance", func() {
+					diff := New(Tolerance(0.01, 0)).CompareArrays([]interface{}{1.0, 2.0, 3.0}, []interface{}{2.001, 1.0, 3.0})
+					changes := diff.Changes()
+					Expect(changes).To(HaveLen(1))
+					Expect(changes[0].Kind()).To(Equal(ChangeMoved))
+					Expect(changes[0].OldValue()).To(Equal(2.0))
+				})
+			})
+
+			Context("With an absolute tolerance for unordered array items", func() {
+				It("Matches the items within the tolerance", func() {
+					diff := New(UnorderedArrays(), Tolerance(0.01, 0)).CompareArrays([]interface{}{1.0, 2.0}, []interface{}{2.001, 1.0})
+					Expect(diff.Modified()).To(BeFalse())
+				})
+			})
+		})
+		Describe("UseNumber", func() {
+			It("Compares large integers exactly", func() {
+				left := []byte(`{"id": 9007199254740993, "amount": 1.10, "other": 2}`)
+				right := []byte(`{"id": 9007199254740992, "amount": 1.1, "other": 2.0}`)
+
+				diff, err := New().Compare(left, right)
+				Expect(err).To(BeNil())
+				Expect(diff.Modified()).To(BeFalse())
+
+				diff, err = New(UseNumber()).Compare(left, right)
+				Expect(err).To(BeNil())
+				Expect(diff.Deltas()).To(Equal([]Delta{
+					NewModified(Name("id"), json.Number("9007199254740993"), json.Number("9007199254740992")),
+				}))
+			})
+
+			It("Compares numbers by their significant digits and exponents", func() {
+				left := []byte(`{"a": 1e100000000, "b": 10e99999999, "c": 0.00, "d": 120, "e": 0.0012, "f": -1e-100000000}`)
+				right := []byte(`{"a": 2e100000000, "b": 1E+100000000, "c": -0e5, "d": 1.2e2, "e": 12e-4, "f": 1e-100000000}`)
+
+				done := make(chan Diff)
+				go func() {
+					defer GinkgoRecover()
+					diff, err := New(UseNumber()).Compare(left, right)
+					Expect(err).To(BeNil())
+					done <- diff
+				}()
+				var diff Diff
+				Eventually(done, "5s").Should(Receive(&diff))
+				Expect(diff.Deltas()).To(ConsistOf(
+					NewModified(Name("a"), json.Number("1e100000000"), json.Number("2e100000000")),
+					NewModified(Name("f"), json.Number("-1e-100000000"), json.Number("1e-100000000")),
+				))
+			})
+
+			It("Applies numbers verbatim", func() {
+				left := []byte(`{"id": 9007199254740993, "list": [1, 2]}`)
+				right := []byte(`{"id": 12345678901234567890, "list": [1, 2, 3.0]}`)
+				differ := New(UseNumber())
+
+				diff, err := differ.Compare(left, right)
+				Expect(err).To(BeNil())
+
+				var patched map[string]interface{}
+				decoder := json.NewDecoder(bytes.NewReader(left))
+				decoder.UseNumber()
+				Expect(decoder.Decode(&patched)).To(Succeed())
+				differ.ApplyPatch(patched, diff)
+				result, err := json.Marshal(patched)
+				Expect(err).To(BeNil())
+				Expect(string(result)).To(Equal(`{"id":12345678901234567890,"list":[1,2,3.0]}`))
+			})
+
+			It("Reports invalid documents", func() {
+				_, err := New(UseNumber()).Compare([]byte(`{"a": 1} {`), []byte(`{}`))
+				Expect(err).NotTo(BeNil())
+			})
+		})
+		Describe("LcsAlgorithm", func() {
+			It("Produces diffs which patch the left side into the right side", func() {
+				fixtures := [][2]string{
+					{"base", "base_changed"},
+					{"add_delete_from", "add_delete_to"},
+					{"changed_types_from", "changed_types_to"},
+					{"move_from", "move_to"},
+					{"keyed_from", "keyed_to"},
+				}
+				for _, fixture := range fixtures {
+					from, to := fixture[0], fixture[1]
+					a := LoadFixture("FIXTURES/" + from + ".json")
+					b := LoadFixture("FIXTURES/" + to + ".json")
+
+					differ := New(LcsAlgorithm(NewLinearLCS))
+					diff := differ.CompareObjects(a, b)
+					Expect(diff.Modified()).To(BeTrue())
+					Expect(differ.ApplyPatch(a, diff)).To(Equal(b))
+				}
+			})
+
+			It("Uses NewLCS for a zero Differ and a nil algorithm", func() {
+				a := map[string]interface{}{"a": []interface{}{1.0, 2.0}}
+				b := map[string]interface{}{"a": []interface{}{2.0, 3.0}}
+				var zero Differ
+				for _, differ := range []*Differ{&zero, New(LcsAlgorithm(nil))} {
+					diff := differ.CompareObjects(a, b)
+					Expect(diff.Deltas()).To(Equal(New().CompareObjects(a, b).Deltas()))
+					Expect(differ.ApplyPatch(deepCopyJson(a), diff)).To(Equal(b))
+				}
+			})
+		})
+		Describe("Patch", func() {
+			It("Patches a copy of the document", func() {
+				fixtures := [][2]string{
+					{"base", "base_changed"},
+					{"add_delete_from", "add_delete_to"},
+					{"changed_types_from", "changed_types_to"},
+					{"long_text_from", "long_text_to"},
+					{"move_from", "move_to"},
+				}
+				for _, fixture := range fixtures {
+					a := LoadFixture("FIXTURES/" + fixture[0] + ".json")
+					b := LoadFixture("FIXTURES/" + fixture[1] + ".json")
+					diff := New().CompareObjects(a, b)
+
+					patched, err := New().Patch(a, diff)
+					Expect(err).To(BeNil())
+					Expect(patched).To(Equal(b), fixture[0])
+					Expect(a).To(Equal(LoadFixture("FIXTURES/"+fixture[0]+".json")), fixture[0])
+				}
+			})
+
+			It("Reports deltas which do not match the document", func() {
+				a := map[string]interface{}{
+					"name":  "left",
+					"gone":  1.0,
+					"obj":   map[string]interface{}{"num": 1.0},
+					"items": []interface{}{"a", "b", "c"},
+				}
+				b := map[string]interface{}{
+					"name":  "right",
+					"obj":   map[string]interface{}{"num": 2.0},
+					"items": []interface{}{"a", "c"},
+				}
+				diff := New().CompareObjects(a, b)
+
+				doc := map[string]interface{}{
+					"name":  "changed",
+					"gone":  1.0,
+					"obj":   "not an object",
+					"items": []interface{}{"a", "x", "c"},
+				}
+				patched, err := New().Patch(doc, diff)
+				Expect(err).To(BeAssignableToTypeOf(&PatchError{}))
+				conflicts := err.(*PatchError).Conflicts
+				Expect(conflicts).To(HaveLen(3))
+				Expect(conflicts[0].Path).To(Equal("/items/1"))
+				Expect(conflicts[0].Delta).To(Equal(NewDeleted(Index(1), "b")))
+				Expect(conflicts[0].Expected).To(Equal("b"))
+				Expect(conflicts[0].Actual).To(Equal("x"))
+				Expect(conflicts[1].Path).To(Equal("/name"))
+				Expect(conflicts[1].Expected).To(Equal("left"))
+				Expect(conflicts[1].Actual).To(Equal("changed"))
+				Expect(conflicts[2].Path).To(Equal("/obj"))
+				Expect(conflicts[2].Reason).To(Equal("not an object"))
+
+				// other deltas are applied
+				Expect(patched).To(Equal(map[string]interface{}{
+					"name":  "changed",
+					"obj":   "not an object",
+					"items": []interface{}{"a", "x", "c"},
+				}))
+			})
+
+			It("Reports text patches which do not apply", func() {
+				a := LoadFixture("FIXTURES/long_text_from.json")
+				b := LoadFixture("FIXTURES/long_text_to.json")
+				diff := New().CompareObjects(a, b)
+
+				_, err := New().Patch(map[string]interface{}{"str": "something else entirely"}, diff)
+				Expect(err).To(BeAssignableToTypeOf(&PatchError{}))
+				Expect(err.(*PatchError).Conflicts[0].Path).To(Equal("/str"))
+			})
+		})
+		Describe("Reverse", func() {
+			fixtures := [][2]string{
+				{"base", "base_changed"},
+				{"add_delete_from", "add_delete_to"},
+				{"changed_types_from", "changed_types_to"},
+				{"long_text_from", "long_text_to"},
+				{"move_from", "move_to"},
+				{"keyed_from", "keyed_to"},
+			}
+
+			It("Unpatches every fixture", func() {
+				for _, fixture := range fixtures {
+					from, to := "FIXTURES/"+fixture[0]+".json", "FIXTURES/"+fixture[1]+".json"
+					differ := New(ArrayKeys("/countries", "name"))
+					diff := differ.CompareObjects(LoadFixture(from), LoadFixture(to))
+
+					patched := differ.ApplyPatch(LoadFixture(from), diff)
+					Expect(patched).To(Equal(LoadFixture(to)), fixture[0])
+					Expect(differ.Unpatch(patched, diff)).To(Equal(LoadFixture(from)), fixture[0])
+				}
+			})
+
+			It("Unpatches unmarshalled deltas", func() {
+				for _, fixture := range fixtures {
+					from, to := "FIXTURES/"+fixture[0]+".json", "FIXTURES/"+fixture[1]+".json"
+					aStr, err := ioutil.ReadFile(from)
+					Expect(err).To(BeNil())
+					bStr, err := ioutil.ReadFile(to)
+					Expect(err).To(BeNil())
+					diff, err := New().Compare(aStr, bStr)
+					Expect(err).To(BeNil())
+
+					deltaString, err := formatter.NewDeltaFormatter().Format(diff)
+					Expect(err).To(BeNil())
+					unmarshalled, err := NewUnmarshaller().UnmarshalString(deltaString)
+					Expect(err).To(BeNil())
+
+					Expect(New().Unpatch(LoadFixture(to), unmarshalled)).To(Equal(LoadFixture(from)), fixture[0])
+				}
+			})
+
+			It("Reverses the deltas", func() {
+				diff := New(ArrayKeys("", "id")).CompareArrays(
+					[]interface{}{
+						map[string]interface{}{"id": 1.0, "v": 1.0},
+						map[string]interface{}{"id": 2.0},
+						map[string]interface{}{"id": 3.0},
+						"x",
+					},
+					[]interface{}{
+						"x",
+						map[string]interface{}{"id": 3.0},
+						map[string]interface{}{"id": 1.0, "v": 2.0},
+						map[string]interface{}{"id": 4.0},
+					},
+				)
+				Expect(diff.Reverse().Deltas()).To(ConsistOf(
+					NewObject(Index(0), []Delta{NewModified(Name("v"), 2.0, 1.0)}),
+					NewMoved(Index(1), Index(2), map[string]interface{}{"id": 3.0}, nil),
+					NewMoved(Index(0), Index(3), "x", nil),
+					NewAdded(Index(1), map[string]interface{}{"id": 2.0}),
+					NewDeleted(Index(3), map[string]interface{}{"id": 4.0}),
+				))
+			})
+
+			It("Unpatches random arrays", func() {
+				random := rand.New(rand.NewSource(1))
+				items := func() []interface{} {
+					array := make([]interface{}, random.Intn(8))
+					for i := range array {
+						if random.Intn(3) == 0 {
+							array[i] = map[string]interface{}{"v": float64(random.Intn(3))}
+						} else {
+							array[i] = float64(random.Intn(5))
+						}
+					}
+					return array
+				}
+				copyOf := func(array []interface{}) []interface{} {
+					data, _ := json.Marshal(array)
+					var copied []interface{}
+					json.Unmarshal(data, &copied)
+					return copied
+				}
+				for i := 0; i < 300; i++ {
+					a, b := items(), items()
+					diff := New().CompareArrays(a, b)
+					Expect(New().Unpatch(copyOf(b), diff)).To(Equal(copyOf(a)), fmt.Sprintf("%v => %v", a, b))
+				}
+			})
+		})
+		Describe("JsonPatch", func() {
+			It("Applies the JSON Patch of every fixture", func() {
+				fixtures := [][2]string{
+					{"base", "base_changed"},
+					{"add_delete_from", "add_delete_to"},
+					{"changed_types_from", "changed_types_to"},
+					{"long_text_from", "long_text_to"},
+					{"move_from", "move_to"},
+				}
+				for _, fixture := range fixtures {
+					a := LoadFixture("FIXTURES/" + fixture[0] + ".json")
+					b := LoadFixture("FIXTURES/" + fixture[1] + ".json")
+					f := formatter.NewJsonPatchFormatter()
+					f.Tests = true
+					patch, err := f.Format(New().CompareObjects(a, b))
+					Expect(err).To(BeNil())
+					operations, err := NewUnmarshaller().UnmarshalJsonPatch([]byte(patch))
+					Expect(err).To(BeNil())
+
+					patched, err := New().ApplyJsonPatch(a, operations)
+					Expect(err).To(BeNil())
+					Expect(patched).To(Equal(b), fixture[0])
+					Expect(a).To(Equal(LoadFixture("FIXTURES/"+fixture[0]+".json")), fixture[0])
+
+					diff, err := New().JsonPatchDiff(a, operations)
+					Expect(err).To(BeNil())
+					Expect(New().ApplyPatch(a, diff)).To(Equal(b), fixture[0])
+				}
+			})
+
+			It("Translates operations into deltas", func() {
+				doc := map[string]interface{}{
+					"name": "left",
+					"list": []interface{}{1.0, 2.0, 3.0, 4.0},
+					"obj":  map[string]interface{}{"x": 1.0},
+				}
+				patch := `[
+					{"op": "test", "path": "/list/2", "value": 3},
+					{"op": "move", "from": "/list/2", "path": "/list/0"},
+					{"op": "replace", "path": "/list/3", "value": 5},
+					{"op": "replace", "path": "/name", "value": "right"},
+					{"op": "remove", "path": "/obj/x"},
+					{"op": "add", "path": "/obj/y", "value": {"z": null}},
+					{"op": "copy", "from": "/obj/y", "path": "/copy"},
+					{"op": "add", "path": "/list/-", "value": 6}
+				]`
+				operations, err := NewUnmarshaller().UnmarshalJsonPatch([]byte(patch))
+				Expect(err).To(BeNil())
+
+				diff, err := New().JsonPatchDiff(doc, operations)
+				Expect(err).To(BeNil())
+				Expect(diff.Deltas()).To(Equal([]Delta{
+					NewArray(Name("list"), []Delta{
+						NewMoved(Index(2), Index(0), 3.0, nil),
+						NewModified(Index(3), 4.0, 5.0),
+						NewAdded(Index(4), 6.0),
+					}),
+					NewModified(Name("name"), "left", "right"),
+					NewObject(Name("obj"), []Delta{
+						NewDeleted(Name("x"), 1.0),
+						NewAdded(Name("y"), map[string]interface{}{"z": nil}),
+					}),
+					NewAdded(Name("copy"), map[string]interface{}{"z": nil}),
+				}))
+
+				patched, err := New().ApplyJsonPatch(doc, operations)
+				Expect(err).To(BeNil())
+				Expect(New().ApplyPatch(doc, diff)).To(Equal(patched))
+			})
+
+			It("Replaces the whole document", func() {
+				operations := []JsonPatchOperation{{Op: JsonPatchReplace, Path: "", Value: "text"}}
+				diff, err := New().JsonPatchDiff([]interface{}{1.0}, operations)
+				Expect(err).To(BeNil())
+				Expect(diff.Deltas()).To(Equal([]Delta{NewModified(Root{}, []interface{}{1.0}, "text")}))
+			})
+
+			It("Applies the JSON Patch of random arrays", func() {
+				random := rand.New(rand.NewSource(1))
+				items := func() []interface{} {
+					array := make([]interface{}, random.Intn(10))
+					for i := range array {
+						if random.Intn(2) == 0 {
+							array[i] = map[string]interface{}{"id": float64(random.Intn(4)), "v": float64(random.Intn(2))}
+						} else {
+							array[i] = float64(random.Intn(5))
+						}
+					}
+					return array
+				}
+				differs := []*Differ{New(), New(ArrayKeys("", "id"))}
+				for i := 0; i < 500; i++ {
+					a, b := items(), items()
+					operations, err := formatter.NewJsonPatchFormatter().Operations(differs[i%2].CompareArrays(a, b))
+					Expect(err).To(BeNil())
+
+					patched, err := New().ApplyJsonPatch(a, operations)
+					Expect(err).To(BeNil())
+					Expect(patched).To(Equal(b), fmt.Sprintf("%v => %v", a, b))
+
+					diff, err := New().JsonPatchDiff(a, operations)
+					Expect(err).To(BeNil())
+					Expect(New().ApplyPatch(a, diff)).To(Equal(b), fmt.Sprintf("%v => %v", a, b))
+				}
+			})
+
+			It("Reports malformed operations", func() {
+				cases := []struct {
+					patch   string
+					index   int
+					pointer string
+					reason  string
+				}{
+					{`[{"op": "add", "path": "/a", "value": 1}, {"op": "add", "path": "/b"}]`, 1, "/b", `missing "value"`},
+					{`[{"op": "jump", "path": "/a"}]`, 0, "/a", `unknown operation "jump"`},
+					{`[{"path": "/a"}]`, 0, "", `missing "op"`},
+					{`[{"op": "move", "path": "/a"}]`, 0, "", `missing "from"`},
+					{`[{"op": "remove", "path": "a"}]`, 0, "a", "invalid JSON Pointer `a`: must be empty or start with '/'"},
+					{`[{"op": "remove", "path": "/a~2"}]`, 0, "/a~2", "invalid JSON Pointer `/a~2`: '~' must be followed by '0' or '1'"},
+					{`[{"op": "move", "from": "/a~", "path": "/b"}]`, 0, "/a~", "invalid JSON Pointer `/a~`: '~' must be followed by '0' or '1'"},
+				}
+				for _, c := range cases {
+					_, err := NewUnmarshaller().UnmarshalJsonPatch([]byte(c.patch))
+					Expect(err).To(Equal(&JsonPatchError{
+						Index:   c.index,
+						Op:      err.(*JsonPatchError).Op,
+						Pointer: c.pointer,
+						Reason:  c.reason,
+					}), c.patch)
+				}
+
+				var operation JsonPatchOperation
+				Expect(json.Unmarshal([]byte(`{"op": "test", "path": "/a"}`), &operation)).NotTo(Succeed())
+				Expect(json.Unmarshal([]byte(`{"op": "test", "path": "/a", "value": null}`), &operation)).To(Succeed())
+				Expect(operation).To(Equal(JsonPatchOperation{Op: JsonPatchTest, Path: "/a"}))
+			})
+
+			It("Reports operations which can not be applied", func() {
+				doc := map[string]interface{}{"list": []interface{}{1.0, 2.0}, "obj": map[string]interface{}{}}
+				cases := []struct {
+					operation JsonPatchOperation
+					pointer   string
+					reason    string
+				}{
+					{JsonPatchOperation{Op: JsonPatchTest, Path: "/list/0", Value: 2.0}, "/list/0", "test failed: the value differs"},
+					{JsonPatchOperation{Op: JsonPatchRemove, Path: "/missing"}, "/missing", "the value does not exist"},
+					{JsonPatchOperation{Op: JsonPatchReplace, Path: "/list/2", Value: 3.0}, "/list/2", "array index 2 out of range"},
+					{JsonPatchOperation{Op: JsonPatchAdd, Path: "/list/01", Value: 3.0}, "/list/01", `invalid array index "01"`},
+					{JsonPatchOperation{Op: JsonPatchAdd, Path: "/list/3", Value: 3.0}, "/list/3", "array index 3 out of range"},
+					{JsonPatchOperation{Op: JsonPatchAdd, Path: "/missing/a", Value: 3.0}, "/missing/a", "the value does not exist"},
+					{JsonPatchOperation{Op: JsonPatchCopy, From: "/none", Path: "/a"}, "/none", `"from": the value does not exist`},
+					{JsonPatchOperation{Op: JsonPatchMove, From: "/obj", Path: "/obj/a"}, "/obj/a", "a value can not be moved into one of its children"},
+					{JsonPatchOperation{Op: JsonPatchRemove, Path: ""}, "", "the whole document can not be removed"},
+				}
+				for _, c := range cases {
+					operations := []JsonPatchOperation{{Op: JsonPatchAdd, Path: "/new", Value: 1.0}, c.operation}
+					_, err := New().ApplyJsonPatch(doc, operations)
+					Expect(err).To(Equal(&JsonPatchError{Index: 1, Op: c.operation.Op, Pointer: c.pointer, Reason: c.reason}))
+				}
+				Expect(doc).To(Equal(map[string]interface{}{"list": []interface{}{1.0, 2.0}, "obj": map[string]interface{}{}}))
+			})
+		})
+		Describe("MergePatch", func() {
+			It("Applies merge patches like RFC 7386", func() {
+				// examples of the appendix of RFC 7386
+				cases := [][3]string{
+					{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+					{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+					{`{"a":"b"}`, `{"a":null}`, `{}`},
+					{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+					{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+					{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+					{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+					{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+					{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+					{`{"a":"b"}`, `["c"]`, `["c"]`},
+					{`{"a":"foo"}`, `null`, `null`},
+					{`{"a":"foo"}`, `"bar"`, `"bar"`},
+					{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+					{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+					{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+				}
+				for _, c := range cases {
+					var target, patch, expected interface{}
+					Expect(json.Unmarshal([]byte(c[0]), &target)).To(Succeed())
+					Expect(json.Unmarshal([]byte(c[1]), &patch)).To(Succeed())
+					Expect(json.Unmarshal([]byte(c[2]), &expected)).To(Succeed())
+
+					// wrapped, as Equal refuses to compare nil values
+					Expect([]interface{}{New().ApplyMergePatch(target, patch)}).To(Equal([]interface{}{expected}), c[1])
+					diff := New().MergePatchDiff(target, patch)
+					Expect([]interface{}{New().ApplyPatch(target, diff)}).To(Equal([]interface{}{expected}), c[1])
+				}
+			})
+
+			It("Translates merge patches into deltas", func() {
+				doc := map[string]interface{}{
+					"name":  "left",
+					"gone":  1.0,
+					"same":  true,
+					"obj":   map[string]interface{}{"num": 1.0, "keep": 2.0},
+					"items": []interface{}{"a", "b", "c"},
+				}
+				patch := map[string]interface{}{
+					"name":    "right",
+					"gone":    nil,
+					"missing": nil,
+					"same":    true,
+					"obj":     map[string]interface{}{"num": 2.0},
+					"items":   []interface{}{"a", "c"},
+					"new":     map[string]interface{}{"a": nil, "b": 1.0},
+				}
+				diff := New().MergePatchDiff(doc, patch)
+				Expect(diff.Deltas()).To(Equal([]Delta{
+					NewDeleted(Name("gone"), 1.0),
+					NewArray(Name("items"), []Delta{NewDeleted(Index(1), "b")}),
+					NewModified(Name("name"), "left", "right"),
+					NewAdded(Name("new"), map[string]interface{}{"b": 1.0}),
+					NewObject(Name("obj"), []Delta{NewModified(Name("num"), 1.0, 2.0)}),
+				}))
+				Expect(doc["obj"]).To(Equal(map[string]interface{}{"num": 1.0, "keep": 2.0}))
+			})
+		})
+		Describe("Merge", func() {
+			It("Combines the changes of both sides", func() {
+				base := map[string]interface{}{"a": 1.0, "b": 1.0, "c": map[string]interface{}{"x": 1.0, "y": 1.0}, "same": 1.0}
+				ours := map[string]interface{}{"a": 2.0, "b": 1.0, "c": map[string]interface{}{"x": 2.0, "y": 1.0}, "same": 2.0}
+				theirs := map[string]interface{}{"a": 1.0, "b": 2.0, "c": map[string]interface{}{"x": 1.0, "y": 2.0}, "d": 1.0, "same": 2.0}
+
+				merged, conflicts, err := New().Merge(base, ours, theirs, nil)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(BeEmpty())
+				Expect(merged).To(Equal(map[string]interface{}{
+					"a": 2.0, "b": 2.0, "c": map[string]interface{}{"x": 2.0, "y": 2.0}, "d": 1.0, "same": 2.0,
+				}))
+				Expect(base).To(Equal(map[string]interface{}{"a": 1.0, "b": 1.0, "c": map[string]interface{}{"x": 1.0, "y": 1.0}, "same": 1.0}))
+			})
+
+			It("Shares no value with the documents", func() {
+				base := map[string]interface{}{"a": 1.0, "list": []interface{}{1.0}}
+				ours := map[string]interface{}{"a": 1.0, "list": []interface{}{1.0}, "x": map[string]interface{}{"k": 1.0}}
+				theirs := map[string]interface{}{"a": 2.0, "list": []interface{}{1.0, []interface{}{2.0}}}
+
+				merged, _, err := New().Merge(base, ours, theirs, nil)
+				Expect(err).To(BeNil())
+				merged.(map[string]interface{})["x"].(map[string]interface{})["k"] = 99.0
+				merged.(map[string]interface{})["list"].([]interface{})[1].([]interface{})[0] = 99.0
+				Expect(ours).To(Equal(map[string]interface{}{"a": 1.0, "list": []interface{}{1.0}, "x": map[string]interface{}{"k": 1.0}}))
+				Expect(theirs).To(Equal(map[string]interface{}{"a": 2.0, "list": []interface{}{1.0, []interface{}{2.0}}}))
+			})
+
+			It("Rebases the indexes of array items", func() {
+				merged, conflicts, err := New().Merge(
+					[]interface{}{1.0, 2.0, 3.0, 4.0, 5.0},
+					[]interface{}{1.0, 3.0, 4.0, 5.0, 6.0},
+					[]interface{}{0.0, 1.0, 2.0, 3.0, 5.0},
+					nil,
+				)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(BeEmpty())
+				Expect(merged).To(Equal([]interface{}{0.0, 1.0, 3.0, 5.0, 6.0}))
+
+				item := func(id float64, value float64) map[string]interface{} {
+					return map[string]interface{}{"id": id, "v": value}
+				}
+				merged, conflicts, err = New(ArrayKeys("/items", "id")).Merge(
+					map[string]interface{}{"items": []interface{}{item(1, 0), item(2, 0), item(3, 0)}},
+					map[string]interface{}{"items": []interface{}{item(2, 0), item(3, 1), item(1, 0)}},
+					map[string]interface{}{"items": []interface{}{item(0, 0), item(1, 2), item(2, 0), item(3, 0)}},
+					nil,
+				)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(BeEmpty())
+				Expect(merged).To(Equal(map[string]interface{}{
+					"items": []interface{}{item(0, 0), item(2, 0), item(3, 1), item(1, 2)},
+				}))
+			})
+
+			It("Resolves conflicts with the strategy", func() {
+				base := map[string]interface{}{"a": 1.0, "b": 1.0, "list": []interface{}{"x", map[string]interface{}{"n": 1.0}}}
+				ours := map[string]interface{}{"a": 2.0, "list": []interface{}{"x"}}
+				theirs := map[string]interface{}{"a": 3.0, "b": 2.0, "list": []interface{}{"x", map[string]interface{}{"n": 2.0}}}
+
+				merged, conflicts, err := New().Merge(base, ours, theirs, MergeFail)
+				Expect(merged).To(Equal(base))
+				Expect(conflicts).To(Equal([]MergeConflict{
+					{Path: "/a", Ours: NewModified(Name("a"), 1.0, 2.0), Theirs: NewModified(Name("a"), 1.0, 3.0)},
+					{Path: "/b", Ours: NewDeleted(Name("b"), 1.0), Theirs: NewModified(Name("b"), 1.0, 2.0)},
+					{
+						Path:   "/list/1",
+						Ours:   NewDeleted(Index(1), map[string]interface{}{"n": 1.0}),
+						Theirs: NewObject(Index(1), []Delta{NewModified(Name("n"), 1.0, 2.0)}),
+					},
+				}))
+				Expect(err).To(Equal(&MergeError{Conflicts: conflicts}))
+
+				merged, conflicts, err = New().Merge(base, ours, theirs, MergeOurs)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(HaveLen(3))
+				Expect(conflicts[0].Resolution).To(Equal(ResolveOurs))
+				Expect(merged).To(Equal(ours))
+
+				merged, _, err = New().Merge(base, ours, theirs, MergeTheirs)
+				Expect(err).To(BeNil())
+				Expect(merged).To(Equal(theirs))
+
+				byPath := func(conflict MergeConflict) MergeResolution {
+					if conflict.Path == "/a" {
+						return ResolveTheirs
+					}
+					return ResolveOurs
+				}
+				merged, _, err = New().Merge(base, ours, theirs, byPath)
+				Expect(err).To(BeNil())
+				Expect(merged).To(Equal(map[string]interface{}{"a": 3.0, "list": []interface{}{"x"}}))
+			})
+
+			It("Merges documents of any type", func() {
+				merged, conflicts, err := New().Merge(1.0, "ours", "theirs", MergeTheirs)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(Equal([]MergeConflict{{
+					Path:       "",
+					Ours:       NewModified(Root{}, 1.0, "ours"),
+					Theirs:     NewModified(Root{}, 1.0, "theirs"),
+					Resolution: ResolveTheirs,
+				}}))
+				Expect(merged).To(Equal("theirs"))
+			})
+
+			It("Keeps the changes of a single side", func() {
+				random := rand.New(rand.NewSource(1))
+				var value func(depth int) interface{}
+				value = func(depth int) interface{} {
+					switch kind := random.Intn(4); {
+					case kind == 0 && depth < 3:
+						object := map[string]interface{}{}
+						for i := random.Intn(4); i > 0; i-- {
+							object[fmt.Sprint(random.Intn(4))] = value(depth + 1)
+						}
+						return object
+					case kind == 1 && depth < 3:
+						array := make([]interface{}, random.Intn(6))
+						for i := range array {
+							array[i] = value(depth + 1)
+						}
+						return array
+					}
+					return float64(random.Intn(3))
+				}
+				for i := 0; i < 500; i++ {
+					base, other := value(0), value(0)
+					merged, conflicts, err := New().Merge(base, other, base, nil)
+					Expect(err).To(BeNil())
+					Expect(conflicts).To(BeEmpty())
+					Expect([]interface{}{merged}).To(Equal([]interface{}{other}), fmt.Sprintf("%v => %v", base, other))
+
+					merged, _, err = New().Merge(base, base, other, nil)
+					Expect(err).To(BeNil())
+					Expect([]interface{}{merged}).To(Equal([]interface{}{other}), fmt.Sprintf("%v => %v", base, other))
+
+					merged, conflicts, err = New().Merge(base, other, other, nil)
+					Expect(err).To(BeNil())
+					Expect(conflicts).To(BeEmpty())
+					Expect([]interface{}{merged}).To(Equal([]interface{}{other}), fmt.Sprintf("%v => %v", base, other))
+				}
+			})
+		})
+		Describe("Compose", func() {
+			It("Squashes consecutive diffs", func() {
+				v1 := map[string]interface{}{"a": 1.0, "b": 1.0, "c": map[string]interface{}{"x": 1.0}, "list": []interface{}{1.0, 2.0, 3.0}}
+				v2 := map[string]interface{}{"a": 2.0, "b": 1.0, "c": map[string]interface{}{"x": 2.0}, "list": []interface{}{0.0, 1.0, 3.0}, "tmp": true}
+				v3 := map[string]interface{}{"a": 3.0, "c": map[string]interface{}{"x": 1.0}, "list": []interface{}{0.0, 3.0, 4.0}}
+
+				composed, err := Compose(New().CompareObjects(v1, v2), New().CompareObjects(v2, v3))
+				Expect(err).To(BeNil())
+				Expect(composed.Deltas()).To(Equal([]Delta{
+					NewModified(Name("a"), 1.0, 3.0),
+					NewDeleted(Name("b"), 1.0),
+					NewArray(Name("list"), []Delta{
+						NewDeleted(Index(0), 1.0),
+						NewDeleted(Index(1), 2.0),
+						NewAdded(Index(0), 0.0),
+						NewAdded(Index(2), 4.0),
+					}),
+				}))
+			})
+
+			It("Fails on diffs which do not follow each other", func() {
+				first := New().CompareObjects(map[string]interface{}{"a": 1.0}, map[string]interface{}{})
+				second := New().CompareObjects(map[string]interface{}{"a": 2.0}, map[string]interface{}{"a": 3.0})
+				_, err := Compose(first, second)
+				Expect(err).To(MatchError("diffs are not consecutive: delta type '*gojsondiff.Modified' at `a` can not follow delta type '*gojsondiff.Deleted'"))
+			})
+
+			It("Fails on diffs of values of different types", func() {
+				first := New().CompareValues(map[string]interface{}{"z": 1.0}, map[string]interface{}{})
+				second := New().CompareValues([]interface{}{1.0}, "s")
+				var err error
+				Expect(func() { _, err = Compose(first, second) }).NotTo(Panic())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("diffs are not consecutive: "))
+
+				first = New().CompareValues(map[string]interface{}{"z": 1.0}, map[string]interface{}{})
+				second = New().CompareValues([]interface{}{1.0}, []interface{}{2.0})
+				Expect(func() { _, err = Compose(first, second) }).NotTo(Panic())
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("diffs are not consecutive: "))
+			})
+
+			It("Is equivalent to the diff between the first and the last documents", func() {
+				random := rand.New(rand.NewSource(1))
+				var value func(depth int) interface{}
+				value = func(depth int) interface{} {
+					switch kind := random.Intn(5); {
+					case kind == 0 && depth < 3:
+						object := map[string]interface{}{}
+						for i := random.Intn(4); i > 0; i-- {
+							object[fmt.Sprint(random.Intn(4))] = value(depth + 1)
+						}
+						return object
+					case kind == 1 && depth < 3:
+						array := make([]interface{}, random.Intn(6))
+						for i := range array {
+							array[i] = value(depth + 1)
+						}
+						return array
+					case kind == 2:
+						return map[string]interface{}{"id": float64(random.Intn(3)), "v": float64(random.Intn(2))}
+					case kind == 3:
+						return fmt.Sprintf("a long enough text to make a text diff %d", random.Intn(3))
+					}
+					return float64(random.Intn(3))
+				}
+				// mutate returns a copy of the value with random changes
+				var mutate func(value interface{}) interface{}
+				mutate = func(v interface{}) interface{} {
+					switch typed := v.(type) {
+					case map[string]interface{}:
+						object := map[string]interface{}{}
+						for name, member := range typed {
+							if random.Intn(5) > 0 {
+								object[name] = mutate(member)
+							}
+						}
+						if random.Intn(3) == 0 {
+							object[fmt.Sprint(random.Intn(4))] = value(2)
+						}
+						return object
+					case []interface{}:
+						array := []interface{}{}
+						for _, item := range typed {
+							if random.Intn(5) > 0 {
+								array = append(array, mutate(item))
+							}
+						}
+						random.Shuffle(len(array), func(i, j int) {
+							if random.Intn(3) == 0 {
+								array[i], array[j] = array[j], array[i]
+							}
+						})
+						for i := random.Intn(3); i > 0; i-- {
+							k := random.Intn(len(array) + 1)
+							array = append(array[:k], append([]interface{}{value(2)}, array[k:]...)...)
+						}
+						return array
+					}
+					if random.Intn(3) == 0 {
+						return value(2)
+					}
+					return v
+				}
+
+				differs := []*Differ{New(), New(ArrayKeys("/**", "id"))}
+				for i := 0; i < 1000; i++ {
+					differ := differs[i%2]
+					v1 := value(0)
+					v2 := mutate(v1)
+					v3 := mutate(v2)
+					message := fmt.Sprintf("%v => %v => %v", v1, v2, v3)
+
+					composed, err := Compose(differ.CompareValues(v1, v2), differ.CompareValues(v2, v3))
+					Expect(err).To(BeNil(), message)
+					expected := New().ApplyPatch(deepCopyJson(v1), differ.CompareValues(v1, v3))
+					Expect([]interface{}{New().ApplyPatch(deepCopyJson(v1), composed)}).To(Equal([]interface{}{expected}), message)
+				}
+			})
+		})
+		Describe("DetectRenames", func() {
+			var a, b map[string]interface{}
+
+			BeforeEach(func() {
+				a = map[string]interface{}{
+					"name":    "go-jsondiff",
+					"owner":   map[string]interface{}{"login": "mrutkows", "id": 1.0, "type": "User"},
+					"license": "Apache-2.0",
+				}
+				b = map[string]interface{}{
+					"title":   "go-jsondiff",
+					"author":  map[string]interface{}{"login": "mrutkows", "id": 1.0, "type": "Organization"},
+					"license": "Apache-2.0",
+				}
+			})
+
+			It("Reports renamed members as deleted and added by default", func() {
+				d := New().CompareObjects(a, b)
+				Expect(d.Deltas()).To(HaveLen(4))
+
+				// a similarity of 0 or less disables the detection
+				Expect(New(DetectRenames(0)).CompareObjects(a, b).Deltas()).To(Equal(d.Deltas()))
+				Expect(New(DetectRenames(-1)).CompareObjects(a, b).Deltas()).To(Equal(d.Deltas()))
+			})
+
+			It("Pairs members with equal values", func() {
+				d := New(DetectRenames(1)).CompareObjects(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewMoved(Name("name"), Name("title"), "go-jsondiff", nil),
+					NewDeleted(Name("owner"), a["owner"]),
+					NewAdded(Name("author"), b["author"]),
+				}))
+			})
+
+			It("Pairs similar members and keeps their changes", func() {
+				d := New(DetectRenames(0.5)).CompareObjects(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewMoved(Name("name"), Name("title"), "go-jsondiff", nil),
+					NewMoved(Name("owner"), Name("author"), a["owner"], NewObject(Name("author"), []Delta{
+						NewModified(Name("type"), "User", "Organization"),
+					})),
+				}))
+
+				patched, err := New().Patch(a, d)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(b))
+				Expect(New().ApplyPatch(deepCopyJson(a), d)).To(Equal(b))
+
+				unpatched, err := New().Patch(b, d.Reverse())
+				Expect(err).To(BeNil())
+				Expect(unpatched).To(Equal(a))
+			})
+
+			It("Reports the ignored paths of the paired members only", func() {
+				a["owner"].(map[string]interface{})["id"] = 1.0
+				a["repo"] = map[string]interface{}{"id": 1.0, "private": false}
+				b["author"].(map[string]interface{})["id"] = 2.0
+				b["project"] = map[string]interface{}{"id": 2.0, "private": false}
+				d := New(DetectRenames(0.5), Ignore("/*/id"), ReportIgnored()).CompareObjects(a, b)
+				Expect(d.Deltas()).To(HaveLen(3))
+				Expect(d.Ignored()).To(Equal([]string{"/project/id", "/author/id"}))
+			})
+
+			It("Reports conflicts of renamed members", func() {
+				d := New(DetectRenames(1)).CompareObjects(a, b)
+				c := deepCopyJson(a).(map[string]interface{})
+				c["name"] = "other"
+				c["title"] = "taken"
+				_, err := New().Patch(c, d)
+				Expect(err).To(BeAssignableToTypeOf(&PatchError{}))
+				Expect(err.(*PatchError).Conflicts[0].Path).To(Equal("/name"))
+			})
+
+			It("Formats renamed members", func() {
+				d := New(DetectRenames(0.5)).CompareObjects(a, b)
+
+				deltaJson, err := formatter.NewDeltaFormatter().FormatAsJson(d)
+				Expect(err).To(BeNil())
+				Expect(deltaJson).To(Equal(map[string]interface{}{
+					"name":   []interface{}{"", "title", formatter.DeltaMove},
+					"owner":  []interface{}{"", "author", formatter.DeltaMove},
+					"author": map[string]interface{}{"type": []interface{}{"User", "Organization"}},
+				}))
+
+				deltaString, err := formatter.NewDeltaFormatter().Format(d)
+				Expect(err).To(BeNil())
+				unmarshalled, err := NewUnmarshaller().UnmarshalString(deltaString)
+				Expect(err).To(BeNil())
+				patched, err := New().Patch(a, unmarshalled)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(b))
+
+				jsonPatch := formatter.NewJsonPatchFormatter()
+				jsonPatch.Tests = true
+				operations, err := jsonPatch.Operations(d)
+				Expect(err).To(BeNil())
+				Expect(operations).To(Equal([]JsonPatchOperation{
+					{Op: JsonPatchTest, Path: "/name", Value: "go-jsondiff"},
+					{Op: JsonPatchMove, From: "/name", Path: "/title"},
+					{Op: JsonPatchTest, Path: "/owner", Value: a["owner"]},
+					{Op: JsonPatchMove, From: "/owner", Path: "/author"},
+					{Op: JsonPatchTest, Path: "/author/type", Value: "User"},
+					{Op: JsonPatchReplace, Path: "/author/type", Value: "Organization"},
+				}))
+				applied, err := New().ApplyJsonPatch(a, operations)
+				Expect(err).To(BeNil())
+				Expect(applied).To(Equal(b))
+
+				mergePatch, err := formatter.NewMergePatchFormatter(a).MergePatch(d)
+				Expect(err).To(BeNil())
+				Expect(New().ApplyMergePatch(a, mergePatch)).To(Equal(b))
+			})
+
+			It("Translates moves of JSON Patches into renames", func() {
+				operations := []JsonPatchOperation{
+					{Op: JsonPatchMove, From: "/owner", Path: "/author"},
+					{Op: JsonPatchReplace, Path: "/author/type", Value: "Organization"},
+					{Op: JsonPatchMove, From: "/name", Path: "/title"},
+				}
+				d, err := New(DetectRenames(1)).JsonPatchDiff(a, operations)
+				Expect(err).To(BeNil())
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewMoved(Name("name"), Name("title"), "go-jsondiff", nil),
+					NewMoved(Name("owner"), Name("author"), a["owner"], NewObject(Name("author"), []Delta{
+						NewModified(Name("type"), "User", "Organization"),
+					})),
+				}))
+
+				d, err = New().JsonPatchDiff(a, operations)
+				Expect(err).To(BeNil())
+				Expect(d.Deltas()).To(HaveLen(4))
+			})
+
+			It("Composes and merges renamed members", func() {
+				differ := New(DetectRenames(0.5))
+				c := deepCopyJson(b).(map[string]interface{})
+				c["license"] = "MIT"
+
+				composed, err := Compose(differ.CompareObjects(a, b), differ.CompareObjects(b, c))
+				Expect(err).To(BeNil())
+				Expect(New().ApplyPatch(deepCopyJson(a), composed)).To(Equal(c))
+
+				theirs := deepCopyJson(a).(map[string]interface{})
+				theirs["license"] = "MIT"
+				merged, conflicts, err := differ.Merge(a, b, theirs, MergeFail)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(BeEmpty())
+				Expect(merged).To(Equal(c))
+			})
+		})
+		Describe("DetectMoves", func() {
+			var a, b []interface{}
+
+			BeforeEach(func() {
+				a = []interface{}{
+					map[string]interface{}{"name": "alpha", "size": 1.0, "tags": []interface{}{"x"}},
+					"beta",
+					"gamma",
+					"delta",
+				}
+				b = []interface{}{
+					"beta",
+					"gamma",
+					"delta",
+					map[string]interface{}{"name": "alpha", "size": 2.0, "tags": []interface{}{"x"}},
+				}
+			})
+
+			It("Reports changed items moved elsewhere as deleted and added by default", func() {
+				for _, differ := range []*Differ{New(), New(DetectMoves(0)), New(DetectMoves(-1))} {
+					Expect(differ.CompareArrays(a, b).Deltas()).To(Equal([]Delta{
+						NewDeleted(Index(0), a[0]),
+						NewAdded(Index(3), b[3]),
+					}))
+				}
+			})
+
+			It("Pairs similar items and keeps their changes", func() {
+				d := New(DetectMoves(0.8)).CompareArrays(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewMoved(Index(0), Index(3), a[0], NewObject(Index(3), []Delta{
+						NewModified(Name("size"), 1.0, 2.0),
+					})),
+				}))
+
+				patched, err := New().Patch(a, d)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(b))
+				Expect(New().ApplyPatch(deepCopyJson(a), d)).To(Equal(b))
+
+				unpatched, err := New().Patch(b, d.Reverse())
+				Expect(err).To(BeNil())
+				Expect(unpatched).To(Equal(a))
+			})
+
+			It("Pairs modified scalars", func() {
+				a := []interface{}{"alpha", 1.0, 2.0, 3.0}
+				b := []interface{}{1.0, 2.0, 3.0, "alphb"}
+				d := New(DetectMoves(0.8)).CompareArrays(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewMoved(Index(0), Index(3), "alpha", NewModified(Index(3), "alpha", "alphb")),
+				}))
+				Expect(New().ApplyPatch(deepCopyJson(a), d)).To(Equal(b))
+			})
+
+			It("Reports the ignored paths of the paired items only", func() {
+				a := []interface{}{
+					map[string]interface{}{"name": "alpha", "size": 1.0, "tags": []interface{}{"x"}, "ts": 1.0},
+					map[string]interface{}{"name": "beta", "size": 1.0, "tags": []interface{}{"y"}, "ts": 1.0},
+					"gamma",
+				}
+				b := []interface{}{
+					"gamma",
+					map[string]interface{}{"name": "beta", "size": 2.0, "tags": []interface{}{"y"}, "ts": 2.0},
+					map[string]interface{}{"name": "alpha", "size": 2.0, "tags": []interface{}{"x"}, "ts": 2.0},
+				}
+				d := New(DetectMoves(0.8), Ignore("/*/ts"), ReportIgnored()).CompareArrays(a, b)
+				Expect(d.Deltas()).To(HaveLen(2))
+				Expect(d.Ignored()).To(Equal([]string{"/2/ts", "/1/ts"}))
+			})
+
+			It("Keeps items modified in place", func() {
+				a := []interface{}{1.0, "alpha", 2.0}
+				b := []interface{}{1.0, "alphb", 2.0}
+				Expect(New(DetectMoves(0.8)).CompareArrays(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Index(1), "alpha", "alphb"),
+				}))
+			})
+
+			It("Leaves dissimilar items deleted and added", func() {
+				d := New(DetectMoves(0.9)).CompareArrays(a, b)
+				Expect(d.Deltas()).To(HaveLen(2))
+			})
+
+			It("Formats and unmarshals moved items with their changes", func() {
+				d := New(DetectMoves(0.8)).CompareArrays(a, b)
+
+				deltaJson, err := formatter.NewDeltaFormatter().FormatAsJson(d)
+				Expect(err).To(BeNil())
+				Expect(deltaJson).To(Equal(map[string]interface{}{
+					"_t": "a",
+					"_0": []interface{}{"", Index(3), formatter.DeltaMove},
+					"3":  map[string]interface{}{"size": []interface{}{1.0, 2.0}},
+				}))
+
+				deltaString, err := formatter.NewDeltaFormatter().Format(d)
+				Expect(err).To(BeNil())
+				unmarshalled, err := NewUnmarshaller().UnmarshalString(deltaString)
+				Expect(err).To(BeNil())
+				patched, err := New().Patch(a, unmarshalled)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(b))
+
+				operations, err := formatter.NewJsonPatchFormatter().Operations(d)
+				Expect(err).To(BeNil())
+				applied, err := New().ApplyJsonPatch(a, operations)
+				Expect(err).To(BeNil())
+				Expect(applied).To(Equal(b))
+			})
+		})
+		Describe("Comparators", func() {
+			isTimestamp := func(value interface{}) bool {
+				text, ok := value.(string)
+				if !ok {
+					return false
+				}
+				_, err := time.Parse(time.RFC3339, text)
+				return err == nil
+			}
+			sameInstant := func(left, right interface{}) (Verdict, float64) {
+				l, _ := time.Parse(time.RFC3339, left.(string))
+				r, _ := time.Parse(time.RFC3339, right.(string))
+				if l.Equal(r) {
+					return VerdictEqual, 0
+				}
+				return VerdictDefer, 0
+			}
+			caseInsensitive := func(left, right interface{}) (Verdict, float64) {
+				l, lok := left.(string)
+				r, rok := right.(string)
+				if !lok || !rok {
+					return VerdictDefer, 0
+				}
+				if strings.EqualFold(l, r) {
+					return VerdictEqual, 0
+				}
+				return VerdictNotEqual, DefaultSimilarity
+			}
+
+			It("Compares values matched by a predicate", func() {
+				differ := New(CompareMatching(isTimestamp, sameInstant))
+				a := map[string]interface{}{"created": "2024-05-01T12:00:00Z", "updated": "2024-05-01T12:00:00Z", "name": "x"}
+				b := map[string]interface{}{"created": "2024-05-01T14:00:00+02:00", "updated": "2024-05-02T12:00:00Z", "name": "x"}
+				Expect(differ.CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Name("updated"), "2024-05-01T12:00:00Z", "2024-05-02T12:00:00Z"),
+				}))
+
+				deletion := New().CompareObjects(map[string]interface{}{"created": "2024-05-01T12:00:00Z", "name": "x"}, map[string]interface{}{"name": "x"})
+				patched, err := differ.Patch(map[string]interface{}{"created": "2024-05-01T14:00:00+02:00", "name": "x"}, deletion)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(map[string]interface{}{"name": "x"}))
+			})
+
+			It("Compares values at the paths matched by a pattern", func() {
+				differ := New(CompareWith("/items/*/status", caseInsensitive))
+				a := map[string]interface{}{"items": []interface{}{
+					map[string]interface{}{"status": "ACTIVE", "kind": "A"},
+				}}
+				b := map[string]interface{}{"items": []interface{}{
+					map[string]interface{}{"status": "active", "kind": "a"},
+				}}
+				Expect(differ.CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewArray(Name("items"), []Delta{
+						NewObject(Index(0), []Delta{NewModified(Name("kind"), "A", "a")}),
+					}),
+				}))
+			})
+
+			It("Makes values differ", func() {
+				differ := New(CompareWith("/version", func(left, right interface{}) (Verdict, float64) {
+					return VerdictNotEqual, 0.5
+				}))
+				a := map[string]interface{}{"version": "1.0.0"}
+				d := differ.CompareObjects(a, map[string]interface{}{"version": "1.0.0"})
+				Expect(d.Modified()).To(BeTrue())
+				Expect(d.Deltas()).To(HaveLen(1))
+				modified := d.Deltas()[0].(*Modified)
+				Expect(modified.PostPosition()).To(Equal(Name("version")))
+				Expect(modified.OldValue).To(Equal("1.0.0"))
+				Expect(modified.NewValue).To(Equal("1.0.0"))
+				Expect(modified.Similarity()).To(Equal(0.5))
+			})
+
+			It("Defers to the comparators added before and to the Differ", func() {
+				differ := New(
+					CompareWith("/**", caseInsensitive),
+					CompareWith("/id", func(left, right interface{}) (Verdict, float64) { return VerdictDefer, 0 }),
+				)
+				a := map[string]interface{}{"id": "A", "n": 1.0}
+				b := map[string]interface{}{"id": "a", "n": 2.0}
+				Expect(differ.CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Name("n"), 1.0, 2.0),
+				}))
+			})
+
+			It("Matches array items by the custom similarity", func() {
+				differ := New(CompareWith("/*", func(left, right interface{}) (Verdict, float64) {
+					if left == "b" && right == "c" {
+						return VerdictNotEqual, 0.9
+					}
+					return VerdictNotEqual, 0.1
+				}))
+				deltas := differ.CompareArrays([]interface{}{"a", "b"}, []interface{}{"c"}).Deltas()
+				Expect(deltas).To(HaveLen(2))
+				Expect(deltas[0]).To(BeAssignableToTypeOf(&Modified{}))
+				Expect(deltas[0].(*Modified).OldValue).To(Equal("b"))
+				Expect(deltas[0].(*Modified).NewValue).To(Equal("c"))
+				Expect(deltas[1]).To(Equal(NewDeleted(Index(0), "a")))
+			})
+
+			It("Matches unordered array items which the comparators make equal", func() {
+				a := map[string]interface{}{"t": []interface{}{"A", "b"}}
+				b := map[string]interface{}{"t": []interface{}{"b", "a"}}
+				diff := New(UnorderedArrays("/t"), CompareWith("/t/*", caseInsensitive)).CompareObjects(a, b)
+				Expect(diff.Modified()).To(BeFalse())
+			})
+
+			It("Matches array items which the comparators make equal", func() {
+				a := map[string]interface{}{"t": []interface{}{"A", "b"}}
+				b := map[string]interface{}{"t": []interface{}{"b", "a"}}
+				differ := New(CompareWith("/t/*", caseInsensitive))
+				diff := differ.CompareObjects(a, b)
+				changes := diff.Changes()
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0].Kind()).To(Equal(ChangeMoved))
+				Expect(differ.ApplyPatch(a, diff)).To(Equal(map[string]interface{}{"t": []interface{}{"b", "A"}}))
+			})
+		})
+		Describe("Normalize", func() {
+			It("Compares normalized strings", func() {
+				differ := New(Normalize("/**", TrimSpace, NFC))
+				a := map[string]interface{}{"name": " Zoe\u0308 ", "tags": []interface{}{"a ", " b"}}
+				b := map[string]interface{}{"name": "Zoë", "tags": []interface{}{"a", "b"}}
+				Expect(differ.CompareObjects(a, b).Modified()).To(BeFalse())
+			})
+
+			It("Compares objects with lowercased keys", func() {
+				differ := New(Normalize("", LowercaseKeys))
+				a := map[string]interface{}{"Name": "x", "ID": 1.0}
+				b := map[string]interface{}{"name": "x", "id": 2.0}
+				Expect(differ.CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Name("id"), 1.0, 2.0),
+				}))
+			})
+
+			It("Compares sorted arrays", func() {
+				differ := New(Normalize("/tags", SortArray))
+				a := map[string]interface{}{"tags": []interface{}{"c", "a"}}
+				b := map[string]interface{}{"tags": []interface{}{"b", "a", "c"}}
+				Expect(differ.CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewArray(Name("tags"), []Delta{NewAdded(Index(1), "b")}),
+				}))
+			})
+
+			It("Drops empty objects", func() {
+				differ := New(Normalize("/**", DropEmptyObjects))
+				a := map[string]interface{}{"a": map[string]interface{}{}, "b": map[string]interface{}{"c": map[string]interface{}{}}, "d": 1.0}
+				b := map[string]interface{}{"d": 1.0}
+				Expect(differ.CompareObjects(a, b).Modified()).To(BeFalse())
+				Expect(differ.CompareObjects(b, a).Modified()).To(BeFalse())
+			})
+
+			It("Records the original or the normalized values", func() {
+				a := map[string]interface{}{"name": " a "}
+				b := map[string]interface{}{"name": " b", "items": []interface{}{map[string]interface{}{"id": " x "}}}
+
+				Expect(New(Normalize("/**", TrimSpace)).CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Name("name"), " a ", " b"),
+					NewAdded(Name("items"), []interface{}{map[string]interface{}{"id": " x "}}),
+				}))
+				Expect(New(Normalize("/**", TrimSpace), RecordNormalized()).CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewModified(Name("name"), "a", "b"),
+					NewAdded(Name("items"), []interface{}{map[string]interface{}{"id": "x"}}),
+				}))
+				Expect(b["items"]).To(Equal([]interface{}{map[string]interface{}{"id": " x "}}))
+			})
+
+			It("Patches documents with normalized values", func() {
+				differ := New(Normalize("/**", TrimSpace))
+				d := differ.CompareObjects(map[string]interface{}{"name": "a", "n": 1.0}, map[string]interface{}{"n": 1.0})
+				patched, err := differ.Patch(map[string]interface{}{"name": " a ", "n": 1.0}, d)
+				Expect(err).To(BeNil())
+				Expect(patched).To(Equal(map[string]interface{}{"n": 1.0}))
+			})
+
+			It("Applies diffs to the documents normalized", func() {
+				cases := []struct {
+					pattern     string
+					normalizer  Normalizer
+					left, right map[string]interface{}
+					patched     map[string]interface{}
+				}{
+					{"/**", TrimSpace,
+						map[string]interface{}{"a": " x ", "b": "y"},
+						map[string]interface{}{"a": "z ", "b": " y"},
+						map[string]interface{}{"a": "z ", "b": "y"}},
+					{"/**", NFC,
+						map[string]interface{}{"n": "Zoe\u0308", "m": "a"},
+						map[string]interface{}{"n": "Zoë", "m": "b"},
+						map[string]interface{}{"n": "Zoe\u0308", "m": "b"}},
+					{"", LowercaseKeys,
+						map[string]interface{}{"A": 1.0, "b": 2.0},
+						map[string]interface{}{"a": 1.0, "B": 3.0},
+						map[string]interface{}{"a": 1.0, "b": 3.0}},
+					{"/t", SortArray,
+						map[string]interface{}{"t": []interface{}{3.0, 1.0, 2.0}},
+						map[string]interface{}{"t": []interface{}{2.0, 1.0, 4.0}},
+						map[string]interface{}{"t": []interface{}{1.0, 2.0, 4.0}}},
+					{"/**", DropEmptyObjects,
+						map[string]interface{}{"a": map[string]interface{}{}, "x": 1.0},
+						map[string]interface{}{"b": map[string]interface{}{"c": map[string]interface{}{}}, "x": 2.0},
+						map[string]interface{}{"x": 2.0}},
+				}
+				for _, c := range cases {
+					differ := New(Normalize(c.pattern, c.normalizer))
+					d := differ.CompareObjects(c.left, c.right)
+
+					patched, err := differ.Patch(c.left, d)
+					Expect(err).To(BeNil())
+					Expect(patched).To(Equal(c.patched))
+					Expect(differ.ApplyPatch(deepCopyJson(c.left), d)).To(Equal(c.patched))
+					Expect(differ.CompareObjects(c.patched, c.right).Modified()).To(BeFalse())
+				}
+			})
+
+			It("Leaves the values untouched by the diffs as they are", func() {
+				left := map[string]interface{}{
+					"keep": " kept ",
+					"text": "the quick brown fox jumps over the lazy dog ",
+					"t":    []interface{}{"b", "a"},
+				}
+				right := map[string]interface{}{
+					"keep": "kept",
+					"text": " the quick brown cat jumps over the lazy dog",
+					"t":    []interface{}{"a", "c"},
+				}
+				patched := map[string]interface{}{
+					"keep": " kept ",
+					"text": " the quick brown cat jumps over the lazy dog",
+					"t":    []interface{}{"a", "c"},
+				}
+				differ := New(Normalize("/**", TrimSpace, SortArray))
+				d := differ.CompareObjects(left, right)
+				result, err := differ.Patch(left, d)
+				Expect(err).To(BeNil())
+				Expect(result).To(Equal(patched))
+				Expect(differ.ApplyPatch(deepCopyJson(left), d)).To(Equal(patched))
+
+				// the text diff of normalized texts is recorded as a Modified
+				differ = New(Normalize("/**", TrimSpace, SortArray), RecordNormalized())
+				d = differ.CompareObjects(left, right)
+				patched["text"] = "the quick brown cat jumps over the lazy dog"
+				result, err = differ.Patch(left, d)
+				Expect(err).To(BeNil())
+				Expect(result).To(Equal(patched))
+				Expect(differ.ApplyPatch(deepCopyJson(left), d)).To(Equal(patched))
+			})
+
+			It("Merges the documents normalized", func() {
+				differ := New(Normalize("/**", SortArray))
+				base := []interface{}{map[string]interface{}{"x": []interface{}{}}, 2.0}
+				merged, conflicts, err := differ.Merge(base, []interface{}{2.0}, deepCopyJson(base), MergeFail)
+				Expect(err).To(BeNil())
+				Expect(conflicts).To(BeEmpty())
+				Expect(merged).To(Equal([]interface{}{2.0}))
+
+				base = []interface{}{map[string]interface{}{"a": []interface{}{map[string]interface{}{"x": 1.0}, []interface{}{1.0}}}}
+				ours := []interface{}{map[string]interface{}{"a": []interface{}{map[string]interface{}{"x": 2.0}, []interface{}{1.0}}}}
+				theirs := []interface{}{map[string]interface{}{"a": []interface{}{[]interface{}{2.0, 1.0}, map[string]interface{}{"x": 1.0}}}}
+				merged, _, err = differ.Merge(base, ours, theirs, MergeFail)
+				Expect(err).To(BeNil())
+				Expect(merged).To(Equal([]interface{}{map[string]interface{}{"a": []interface{}{[]interface{}{1.0, 2.0}, map[string]interface{}{"x": 2.0}}}}))
+
+				differ = New(Normalize("/**", LowercaseKeys))
+				merged, _, err = differ.Merge(
+					map[string]interface{}{"A": map[string]interface{}{"b": 1.0}},
+					map[string]interface{}{"a": map[string]interface{}{"b": 2.0}},
+					map[string]interface{}{"A": map[string]interface{}{"B": 1.0, "c": 1.0}},
+					MergeFail,
+				)
+				Expect(err).To(BeNil())
+				Expect(merged).To(Equal(map[string]interface{}{"a": map[string]interface{}{"b": 2.0, "c": 1.0}}))
+			})
+
+			It("Unpatches the documents normalized", func() {
+				cases := []struct {
+					normalizer  Normalizer
+					left, right interface{}
+					unpatched   interface{}
+				}{
+					{SortArray,
+						map[string]interface{}{"t": []interface{}{3.0, map[string]interface{}{"a": 1.0}, 2.0}},
+						map[string]interface{}{"t": []interface{}{2.0, map[string]interface{}{"a": 2.0}, 0.0, 3.0}},
+						map[string]interface{}{"t": []interface{}{2.0, 3.0, map[string]interface{}{"a": 1.0}}}},
+					{LowercaseKeys,
+						map[string]interface{}{"A": map[string]interface{}{"X": 1.0}, "b": 2.0},
+						map[string]interface{}{"a": map[string]interface{}{"x": 2.0}, "B": 3.0},
+						map[string]interface{}{"a": map[string]interface{}{"x": 1.0}, "b": 2.0}},
+				}
+				for _, c := range cases {
+					differ := New(Normalize("/**", c.normalizer))
+					d := differ.CompareValues(c.left, c.right)
+					Expect(differ.Unpatch(deepCopyJson(c.right), d)).To(Equal(c.unpatched))
+					unpatched, err := differ.Patch(c.right, d.Reverse())
+					Expect(err).To(BeNil())
+					Expect(unpatched).To(Equal(c.unpatched))
+				}
+			})
+
+			It("Applies the JSON Patches of the documents normalized", func() {
+				cases := []struct {
+					normalizer  Normalizer
+					left, right interface{}
+					patched     interface{}
+				}{
+					{SortArray,
+						[]interface{}{map[string]interface{}{"x": []interface{}{}}, 2.0},
+						[]interface{}{2.0, map[string]interface{}{"x": []interface{}{3.0, 1.0}}},
+						[]interface{}{2.0, map[string]interface{}{"x": []interface{}{1.0, 3.0}}}},
+					{LowercaseKeys,
+						map[string]interface{}{"A": []interface{}{1.0}, "B": map[string]interface{}{"C": 1.0}},
+						map[string]interface{}{"a": []interface{}{2.0}, "b": map[string]interface{}{"c": 1.0, "D": 2.0}},
+						map[string]interface{}{"a": []interface{}{2.0}, "b": map[string]interface{}{"c": 1.0, "d": 2.0}}},
+				}
+				for _, c := range cases {
+					differ := New(Normalize("/**", c.normalizer))
+					d := differ.CompareValues(c.left, c.right)
+					operations, err := formatter.NewJsonPatchFormatter().Operations(d)
+					Expect(err).To(BeNil())
+					patched, err := differ.ApplyJsonPatch(c.left, operations)
+					Expect(err).To(BeNil())
+					Expect(patched).To(Equal(c.patched))
+				}
+			})
+		})
+
+		Describe("NullAsMissing and EmptyAsMissing", func() {
+			It("Hides null members which are missing on the other side", func() {
+				a := map[string]interface{}{"a": 1.0, "b": nil}
+				b := map[string]interface{}{"a": 1.0, "c": nil, "d": []interface{}{}}
+				d := New(NullAsMissing()).CompareObjects(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{NewAdded(Name("d"), []interface{}{})}))
+				Expect(d.Strict().Deltas()).To(Equal([]Delta{
+					NewDeleted(Name("b"), nil),
+					NewAdded(Name("c"), nil),
+					NewAdded(Name("d"), []interface{}{}),
+				}))
+				Expect(New().ApplyPatch(deepCopyJson(a), d.Strict())).To(Equal(b))
+			})
+
+			It("Hides empty containers at the paths matched by the patterns", func() {
+				a := map[string]interface{}{"spec": map[string]interface{}{"x": map[string]interface{}{}, "y": []interface{}{}}, "meta": map[string]interface{}{}}
+				b := map[string]interface{}{"spec": map[string]interface{}{}}
+				d := New(EmptyAsMissing("/spec/*")).CompareObjects(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{NewDeleted(Name("meta"), map[string]interface{}{})}))
+				Expect(New().ApplyPatch(deepCopyJson(a), d.Strict())).To(Equal(b))
+			})
+
+			It("Hides members of array items", func() {
+				a := map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": 1.0, "a": nil}, "x"}}
+				b := map[string]interface{}{"items": []interface{}{"x", map[string]interface{}{"id": 1.0, "b": []interface{}{}}}}
+				d := New(NullAsMissing(), EmptyAsMissing(), ArrayKeys("/items", "id")).CompareObjects(a, b)
+				Expect(d.Deltas()).To(Equal([]Delta{
+					NewArray(Name("items"), []Delta{NewMoved(Index(1), Index(0), "x", nil)}),
+				}))
+				Expect(New().ApplyPatch(deepCopyJson(a), d.Strict())).To(Equal(b))
+				Expect(New().ApplyPatch(deepCopyJson(b), d.Reverse().Strict())).To(Equal(a))
+			})
+		})
+
+		Describe("CoerceTypes", func() {
+			a := map[string]interface{}{"n": "42", "b": "true", "f": 1.5, "m": "43", "s": "x"}
+			b := map[string]interface{}{"n": 42.0, "b": true, "f": "1.50", "m": 42.0, "s": 1.0}
+
+			It("Reports equivalent values of other types as type changes", func() {
+				Expect(New(CoerceTypes()).CompareObjects(a, b).Deltas()).To(Equal([]Delta{
+					NewTypeChanged(Name("b"), "true", true),
+					NewTypeChanged(Name("f"), 1.5, "1.50"),
+					NewModified(Name("m"), "43", 42.0),
+					NewTypeChanged(Name("n"), "42", 42.0),
+					NewModified(Name("s"), "x", 1.0),
+				}))
+				Expect(New().CompareObjects(a, b).Deltas()).To(ContainElement(NewModified(Name("n"), "42", 42.0)))
+			})
+
+			It("Coerces the values at the paths matched by the patterns", func() {
+				differ := New(CoerceTypes("/n", "/price"), Tolerance(0.01, 0, "/price"))
+				Expect(differ.CompareObjects(
+					map[string]interface{}{"n": "42", "b": "true", "price": "9.999"},
+					map[string]interface{}{"n": 42.0, "b": true, "price": 10.0},
+				).Deltas()).To(Equal([]Delta{
+					NewModified(Name("b"), "true", true),
+					NewTypeChanged(Name("n"), "42", 42.0),
+					NewTypeChanged(Name("price"), "9.999", 10.0),
+				}))
+			})
+
+			It("Applies and reverses type changes", func() {
+				d := New(CoerceTypes()).CompareObjects(a, b)
+				Expect(New().ApplyPatch(deepCopyJson(a), d)).To(Equal(b))
+				Expect(New().ApplyPatch(deepCopyJson(b), d.Reverse())).To(Equal(a))
+			})
+		})
+
+		Describe("Walk", func() {
+			a := map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a", "image": "x:1"},
+				map[string]interface{}{"name": "b", "image": "y:1"},
+				map[string]interface{}{"name": "c", "image": "z:1"},
+				map[string]interface{}{"name": "e", "image": "v:1"},
+			}}, "old": 1.0}
+			b := map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "b", "image": "y:2"},
+				map[string]interface{}{"name": "e", "image": "v:2"},
+				map[string]interface{}{"name": "c", "image": "z:1"},
+				map[string]interface{}{"name": "d", "image": "w:1"},
+			}}, "new": 2.0}
+			d := New(ArrayKeys("/spec/containers", "name")).CompareObjects(a, b)
+			describe := func(change Change) string {
+				return fmt.Sprintf("%T %v %v", change.Delta, change.PrePath, change.PostPath)
+			}
+
+			It("Visits the deltas with their paths", func() {
+				visited := make([]string, 0)
+				Walk(d, func(change Change) bool {
+					visited = append(visited, describe(change))
+					return true
+				})
+				Expect(visited).To(Equal([]string{
+					"*gojsondiff.Deleted /old ",
+					"*gojsondiff.Object /spec /spec",
+					"*gojsondiff.Array /spec/containers /spec/containers",
+					"*gojsondiff.Object /spec/containers/1 /spec/containers/0",
+					"*gojsondiff.Modified /spec/containers/1/image /spec/containers/0/image",
+					"*gojsondiff.Moved /spec/containers/3 /spec/containers/1",
+					"*gojsondiff.Object /spec/containers/3 /spec/containers/1",
+					"*gojsondiff.Modified /spec/containers/3/image /spec/containers/1/image",
+					"*gojsondiff.Deleted /spec/containers/0 ",
+					"*gojsondiff.Added  /spec/containers/3",
+					"*gojsondiff.Added  /new",
+				}))
+			})
+
+			It("Skips the nested deltas", func() {
+				visited := make([]string, 0)
+				Walk(d, func(change Change) bool {
+					visited = append(visited, change.Pointer())
+					return change.Pointer() != "/spec"
+				})
+				Expect(visited).To(Equal([]string{"/old", "/spec", "/new"}))
+			})
+
+			It("Lists the changes", func() {
+				changes := d.Changes()
+				pointers := make([]string, len(changes))
+				for i, change := range changes {
+					pointers[i] = change.Pointer()
+				}
+				Expect(pointers).To(Equal([]string{
+					"/old",
+					"/spec/containers/0/image",
+					"/spec/containers/1",
+					"/spec/containers/1/image",
+					"/spec/containers/0",
+					"/spec/containers/3",
+					"/new",
+				}))
+				Expect(changes[1].PrePath).To(Equal(Path{Name("spec"), Name("containers"), Index(1), Name("image")}))
+			})
+
+			It("Visits deltas of the whole document", func() {
+				changes := New().CompareValues("a", 1.0).Changes()
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0].PrePath).To(Equal(Path{}))
+				Expect(changes[0].PostPath).To(Equal(Path{}))
+				Expect(changes[0].Pointer()).To(Equal(""))
+			})
+		})
+
+		Describe("Filter and Select", func() {
+			a := map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "a", "image": "x:1"},
+				map[string]interface{}{"name": "b", "image": "y:1"},
+				map[string]interface{}{"name": "c", "image": "z:1"},
+				map[string]interface{}{"name": "e", "image": "v:1"},
+			}}, "old": 1.0}
+			b := map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+				map[string]interface{}{"name": "b", "image": "y:2"},
+				map[string]interface{}{"name": "e", "image": "v:2"},
+				map[string]interface{}{"name": "c", "image": "z:1"},
+				map[string]interface{}{"name": "d", "image": "w:1"},
+			}}, "new": 2.0}
+			differ := New(ArrayKeys("/spec/containers", "name"))
+			d := differ.CompareObjects(a, b)
+			container := func(name, image string) interface{} {
+				return map[string]interface{}{"name": name, "image": image}
+			}
+
+			It("Selects the changes of a subtree", func() {
+				selected, err := d.Select("/spec")
+				Expect(err).To(BeNil())
+				Expect(selected.Deltas()).To(HaveLen(1))
+				patched := differ.ApplyPatch(deepCopyJson(a), selected)
+				Expect(patched).To(Equal(map[string]interface{}{"spec": b["spec"], "old": 1.0}))
+			})
+
+			It("Selects the changes matched by wildcards", func() {
+				selected, err := d.Select("/spec/containers/*/image")
+				Expect(err).To(BeNil())
+				patched := differ.ApplyPatch(deepCopyJson(a), selected)
+				Expect(patched).To(Equal(map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+					container("a", "x:1"), container("b", "y:2"), container("c", "z:1"), container("e", "v:2"),
+				}}, "old": 1.0}))
+				Expect(selected.Filter(func(change Change) bool { return false }).Modified()).To(BeFalse())
+			})
+
+			It("Puts back the items whose deletion or move is dropped", func() {
+				filtered := d.Filter(func(change Change) bool {
+					switch change.Delta.(type) {
+					case *Deleted, *Moved:
+						return false
+					}
+					return true
+				})
+				patched := differ.ApplyPatch(deepCopyJson(a), filtered)
+				Expect(patched).To(Equal(map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+					container("a", "x:1"), container("b", "y:2"), container("c", "z:1"), container("e", "v:2"), container("d", "w:1"),
+				}}, "old": 1.0, "new": 2.0}))
+			})
+
+			It("Drops the items whose addition is dropped", func() {
+				filtered := d.Filter(func(change Change) bool {
+					_, added := change.Delta.(*Added)
+					return !added
+				})
+				patched := differ.ApplyPatch(deepCopyJson(a), filtered)
+				Expect(patched).To(Equal(map[string]interface{}{"spec": map[string]interface{}{"containers": []interface{}{
+					container("b", "y:2"), container("e", "v:2"), container("c", "z:1"),
+				}}}))
+			})
+
+			It("Rejects invalid patterns", func() {
+				_, err := d.Select("spec")
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Describe("ApplySelected", func() {
+			a := map[string]interface{}{
+				"name":  "app",
+				"steps": []interface{}{"build", "lint", "test", "deploy"},
+				"env":   map[string]interface{}{"LEVEL": "debug", "PORT": "8080"},
+			}
+			b := map[string]interface{}{
+				"name":  "service",
+				"steps": []interface{}{"deploy", "build", "test", "scan"},
+				"env":   map[string]interface{}{"LEVEL": "info", "PORT": 8080.0},
+			}
+			differ := New(CoerceTypes("/env/*"))
+			d := differ.CompareObjects(a, b)
+
+			It("Gives the path, kind and values of the changes to the decision", func() {
+				decided := make([]string, 0)
+				differ.ApplySelected(deepCopyJson(a), d, func(change Change) bool {
+					decided = append(decided, fmt.Sprintf("%s %s %v %v", change.Pointer(), change.Kind(), change.OldValue(), change.NewValue()))
+					return true
+				})
+				Expect(decided).To(ConsistOf(
+					"/env/LEVEL modified debug info",
+					"/env/PORT type 8080 8080",
+					"/name modified app service",
+					"/steps/1 deleted lint <nil>",
+					"/steps/3 added <nil> scan",
+					"/steps/0 moved deploy deploy",
+				))
+			})
+
+			It("Applies the accepted changes only", func() {
+				patched, applied := differ.ApplySelected(deepCopyJson(a), d, func(change Change) bool {
+					return change.Kind() != ChangeType && change.Pointer() != "/name"
+				})
+				Expect(patched).To(Equal(map[string]interface{}{
+					"name":  "app",
+					"steps": []interface{}{"deploy", "build", "test", "scan"},
+					"env":   map[string]interface{}{"LEVEL": "info", "PORT": "8080"},
+				}))
+				Expect(differ.ApplyPatch(deepCopyJson(a), applied)).To(Equal(patched))
+			})
+
+			It("Rewrites the indexes of the items when siblings are rejected", func() {
+				patched, _ := differ.ApplySelected(deepCopyJson(a), d, func(change Change) bool {
+					return change.Kind() == ChangeAdded
+				})
+				Expect(patched.(map[string]interface{})["steps"]).To(Equal([]interface{}{"build", "lint", "test", "deploy", "scan"}))
+
+				patched, _ = differ.ApplySelected(deepCopyJson(a), d, func(change Change) bool {
+					return change.Kind() != ChangeMoved
+				})
+				Expect(patched.(map[string]interface{})["steps"]).To(Equal([]interface{}{"build", "test", "deploy", "scan"}))
+
+				patched, _ = differ.ApplySelected(deepCopyJson(a), d, func(change Change) bool {
+					return change.Kind() == ChangeMoved
+				})
+				Expect(patched.(map[string]interface{})["steps"]).To(Equal([]interface{}{"deploy", "build", "lint", "test"}))
+			})
+
+			It("Applies all or none of the changes", func() {
+				patched, _ := differ.ApplySelected(deepCopyJson(a), d, AcceptAll)
+				Expect(patched).To(Equal(b))
+				patched, applied := differ.ApplySelected(deepCopyJson(a), d, RejectAll)
+				Expect(patched).To(Equal(a))
+				Expect(applied.Modified()).To(BeFalse())
+			})
+		})
+
+		Describe("Budgets", func() {
+
+			var (
+				a, b map[string]interface{}
+			)
+
+			BeforeEach(func() {
+				a = map[string]interface{}{
+					"arr":    []interface{}{"a", "b", "c", "d", "e", "f"},
+					"nested": map[string]interface{}{"obj": map[string]interface{}{"num": 1.0}},
+				}
+				b = map[string]interface{}{
+					"arr":    []interface{}{"a", "x", "d", "c", "y", "z", "f"},
+					"nested": map[string]interface{}{"obj": map[string]interface{}{"num": 2.0}},
+				}
+			})
+
+			Context("The context is cancelled", func() {
+				It("Returns the error of the context", func() {
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					diff, err := New().CompareObjectsContext(ctx, a, b)
+					Expect(diff).To(BeNil())
+					Expect(err).To(Equal(context.Canceled))
+				})
+			})
+
+			Context("Arrays exceed MaxLcsCells", func() {
+				It("Compares the items by position", func() {
+					differ := New(MaxLcsCells(10))
+					diff, err := differ.CompareObjectsContext(context.Background(), a, b)
+					Expect(err).To(BeNil())
+					Expect(diff.Deltas()).To(ContainElement(NewArray(Name("arr"), []Delta{
+						NewModified(Index(1), "b", "x"),
+						NewModified(Index(2), "c", "d"),
+						NewModified(Index(3), "d", "c"),
+						NewModified(Index(4), "e", "y"),
+						NewAdded(Index(5), "z"),
+					})))
+					Expect(differ.ApplyPatch(a, diff)).To(Equal(b))
+				})
+
+				It("Returns a BudgetError with FailOnBudget", func() {
+					_, err := New(MaxLcsCells(10), FailOnBudget()).CompareObjectsContext(context.Background(), a, b)
+					Expect(err).To(Equal(&BudgetError{Budget: BudgetLcsCells, Path: "/arr", Limit: 10, Required: 20}))
+				})
+
+				It("Degrades when errors cannot be reported", func() {
+					diff := New(MaxLcsCells(10), FailOnBudget()).CompareObjects(a, b)
+					Expect(diff.Modified()).To(BeTrue())
+				})
+			})
+
+			Context("Unmatched array items exceed MaxSimilarityCells", func() {
+				It("Reports them as deleted and added", func() {
+					diff := New(MaxSimilarityCells(1)).CompareArrays(
+						[]interface{}{"a", "b", "c"},
+						[]interface{}{"a", "x", "y"},
+					)
+					Expect(diff.Deltas()).To(ConsistOf(
+						NewDeleted(Index(1), "b"),
+						NewDeleted(Index(2), "c"),
+						NewAdded(Index(1), "x"),
+						NewAdded(Index(2), "y"),
+					))
+				})
+			})
+
+			Context("Values are nested deeper than MaxDepth", func() {
+				It("Compares them as a whole", func() {
+					diff := New(MaxDepth(1)).CompareObjects(a, b)
+					Expect(diff.Deltas()).To(ContainElement(NewObject(Name("nested"), []Delta{
+						NewModified(Name("obj"), a["nested"].(map[string]interface{})["obj"], b["nested"].(map[string]interface{})["obj"]),
+					})))
+				})
+
+				It("Returns a BudgetError with FailOnBudget", func() {
+					_, err := New(MaxDepth(1), FailOnBudget()).Compare([]byte(`{"a": {"b": {}}}`), []byte(`{"a": {"b": {}}}`))
+					Expect(err).To(Equal(&BudgetError{Budget: BudgetDepth, Path: "/a/b", Limit: 1, Required: 2}))
+				})
+			})
 		})
 		Describe("Compare", func() {
 			Context("There are some values modified", func() {
@@ -135,6 +2138,65 @@ var _ = Describe("Gojsondiff", func() {
 					Expect(diffStr).To(Equal(diffObj))
 				})
 			})
+
+			Context("The documents are arrays", func() {
+				It("Detects changes and patches the array", func() {
+					differ := New()
+					d, err := differ.Compare([]byte(`[1, 2, 3]`), []byte(`[1, 3, 4]`))
+					Expect(err).To(BeNil())
+					Expect(d.Modified()).To(BeTrue())
+
+					var a interface{} = []interface{}{1.0, 2.0, 3.0}
+					Expect(differ.ApplyPatch(a, d)).To(Equal([]interface{}{1.0, 3.0, 4.0}))
+				})
+			})
+
+			Context("The documents are scalars", func() {
+				It("Detects a change of the whole document", func() {
+					d, err := New().Compare([]byte(`"abc"`), []byte(`42`))
+					Expect(err).To(BeNil())
+					Expect(d.Deltas()).To(Equal([]Delta{NewModified(Root{}, "abc", 42.0)}))
+					Expect(New().ApplyPatch("abc", d)).To(Equal(42.0))
+				})
+
+				It("Leaves a document which the text diff does not apply to", func() {
+					left := "The quick brown fox jumps over the lazy dog"
+					right := "The quick brown cat jumps over the lazy dog"
+					d, err := New().Compare([]byte(`"`+left+`"`), []byte(`"`+right+`"`))
+					Expect(err).To(BeNil())
+					Expect(d.Deltas()[0]).To(BeAssignableToTypeOf(&TextDiff{}))
+					Expect(New().ApplyPatch(left, d)).To(Equal(right))
+
+					Expect(New().ApplyPatch("Lorem ipsum dolor sit amet", d)).To(Equal("Lorem ipsum dolor sit amet"))
+					Expect(New().ApplyPatch(42.0, d)).To(Equal(42.0))
+					Expect(d.Deltas()[0].(*TextDiff).NewValue).To(Equal(right))
+				})
+
+				It("Detects nothing for equal documents", func() {
+					d, err := New().Compare([]byte(`null`), []byte(`null`))
+					Expect(err).To(BeNil())
+					Expect(d.Modified()).To(BeFalse())
+				})
+			})
+
+			Context("The documents have different types", func() {
+				It("Replaces the whole document", func() {
+					left := map[string]interface{}{"a": 1.0}
+					right := []interface{}{"a"}
+					d := New().CompareValues(left, right)
+					Expect(d.Deltas()).To(Equal([]Delta{NewModified(Root{}, left, right)}))
+					Expect(New().ApplyPatch(left, d)).To(Equal(right))
+				})
+			})
 		})
 	})
 })
+
+// deepCopyJson returns a copy of a JSON value.
+func deepCopyJson(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	Expect(err).To(BeNil())
+	var copied interface{}
+	Expect(json.Unmarshal(data, &copied)).To(Succeed())
+	return copied
+}
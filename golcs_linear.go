@@ -0,0 +1,263 @@
+package gojsondiff
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// rowsBetweenChecks is the number of rows of the LCS computed between two
+// checks of the context.
+const rowsBetweenChecks = 64
+
+// linearLcs implements Lcs with the linear-space algorithm of Hirschberg.
+// Items are hashed once up front, so that the algorithm only compares ints,
+// and the common prefix and suffix of the arrays are trimmed before it runs.
+type linearLcs struct {
+	left  []interface{}
+	right []interface{}
+	/* for caching */
+	indexPairs []IndexPair
+	values     []interface{}
+}
+
+// NewLinearLCS creates a new LCS calculator from two arrays which uses
+// O(len(left)+len(right)) memory instead of the O(len(left)*len(right)) table
+// of NewLCS. The length of the LCS is the same, but when several subsequences
+// have that length the one returned may differ.
+func NewLinearLCS(left, right []interface{}) Lcs {
+	return &linearLcs{
+		left:  left,
+		right: right,
+	}
+}
+
+// Length implements Lcs.Length()
+func (lcs *linearLcs) Length() (length int) {
+	length, _ = lcs.LengthContext(context.Background())
+	return length
+}
+
+// LengthContext implements Lcs.LengthContext()
+func (lcs *linearLcs) LengthContext(ctx context.Context) (length int, err error) {
+	pairs, err := lcs.IndexPairsContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return len(pairs), nil
+}
+
+// IndexPairs implements Lcs.IndexPairs()
+func (lcs *linearLcs) IndexPairs() (pairs []IndexPair) {
+	pairs, _ = lcs.IndexPairsContext(context.Background())
+	return pairs
+}
+
+// IndexPairsContext implements Lcs.IndexPairsContext()
+func (lcs *linearLcs) IndexPairsContext(ctx context.Context) (pairs []IndexPair, err error) {
+	if lcs.indexPairs != nil {
+		return lcs.indexPairs, nil
+	}
+
+	leftIds, rightIds := itemIds(lcs.left, lcs.right)
+
+	prefix := 0
+	for prefix < len(leftIds) && prefix < len(rightIds) && leftIds[prefix] == rightIds[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(leftIds)-prefix && suffix < len(rightIds)-prefix &&
+		leftIds[len(leftIds)-1-suffix] == rightIds[len(rightIds)-1-suffix] {
+		suffix++
+	}
+
+	pairs = make([]IndexPair, 0, prefix+suffix)
+	for i := 0; i < prefix; i++ {
+		pairs = append(pairs, IndexPair{Left: i, Right: i})
+	}
+
+	middle := &hirschberg{
+		ctx:      ctx,
+		forward:  make([]int, len(rightIds)-prefix-suffix+1),
+		backward: make([]int, len(rightIds)-prefix-suffix+1),
+		pairs:    pairs,
+	}
+	err = middle.solve(
+		leftIds[prefix:len(leftIds)-suffix], prefix,
+		rightIds[prefix:len(rightIds)-suffix], prefix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	pairs = middle.pairs
+
+	for i := 0; i < suffix; i++ {
+		pairs = append(pairs, IndexPair{Left: len(leftIds) - suffix + i, Right: len(rightIds) - suffix + i})
+	}
+
+	lcs.indexPairs = pairs
+	return pairs, nil
+}
+
+// Values implements Lcs.Values()
+func (lcs *linearLcs) Values() (values []interface{}) {
+	values, _ = lcs.ValuesContext(context.Background())
+	return values
+}
+
+// ValuesContext implements Lcs.ValuesContext()
+func (lcs *linearLcs) ValuesContext(ctx context.Context) (values []interface{}, err error) {
+	if lcs.values != nil {
+		return lcs.values, nil
+	}
+
+	pairs, err := lcs.IndexPairsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	values = make([]interface{}, len(pairs))
+	for i, pair := range pairs {
+		values[i] = lcs.left[pair.Left]
+	}
+	lcs.values = values
+
+	return values, nil
+}
+
+// Left implements Lcs.Left()
+func (lcs *linearLcs) Left() (leftValues []interface{}) {
+	return lcs.left
+}
+
+// Right implements Lcs.Right()
+func (lcs *linearLcs) Right() (rightValues []interface{}) {
+	return lcs.right
+}
+
+// itemIds returns the ids of the items of both arrays, equal items having the
+// same id. Items are bucketed by their printed value and compared with
+// reflect.DeepEqual only to the items of their bucket.
+func itemIds(left, right []interface{}) (leftIds, rightIds []int) {
+	buckets := make(map[string][]int, len(left)+len(right))
+	representatives := make([]interface{}, 0, len(left)+len(right))
+	idOf := func(item interface{}) int {
+		bucket := fmt.Sprintf("%#v", item)
+		for _, id := range buckets[bucket] {
+			if reflect.DeepEqual(representatives[id], item) {
+				return id
+			}
+		}
+		id := len(representatives)
+		representatives = append(representatives, item)
+		buckets[bucket] = append(buckets[bucket], id)
+		return id
+	}
+
+	leftIds = make([]int, len(left))
+	for i, item := range left {
+		leftIds[i] = idOf(item)
+	}
+	rightIds = make([]int, len(right))
+	for i, item := range right {
+		rightIds[i] = idOf(item)
+	}
+	return leftIds, rightIds
+}
+
+// hirschberg holds the state of the divide and conquer LCS algorithm.
+// The rows are reused by every step, as each step is done with them before
+// recursing.
+type hirschberg struct {
+	ctx      context.Context
+	forward  []int
+	backward []int
+	pairs    []IndexPair
+}
+
+// solve appends the LCS index pairs of a and b, offset by the positions of a
+// and b in the whole arrays, to the pairs.
+func (h *hirschberg) solve(a []int, aOffset int, b []int, bOffset int) error {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	if len(a) == 1 {
+		for j, id := range b {
+			if id == a[0] {
+				h.pairs = append(h.pairs, IndexPair{Left: aOffset, Right: bOffset + j})
+				break
+			}
+		}
+		return nil
+	}
+
+	// split b where the LCS of the halves of a add up to the longest
+	mid := len(a) / 2
+	if err := h.lengths(a[:mid], b, h.forward); err != nil {
+		return err
+	}
+	if err := h.reverseLengths(a[mid:], b, h.backward); err != nil {
+		return err
+	}
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if length := h.forward[j] + h.backward[j]; length > best {
+			split, best = j, length
+		}
+	}
+
+	if err := h.solve(a[:mid], aOffset, b[:split], bOffset); err != nil {
+		return err
+	}
+	return h.solve(a[mid:], aOffset+mid, b[split:], bOffset+split)
+}
+
+// lengths sets row[j] to the length of the LCS of a and b[:j].
+func (h *hirschberg) lengths(a []int, b []int, row []int) error {
+	for j := 0; j <= len(b); j++ {
+		row[j] = 0
+	}
+	for i, id := range a {
+		if i%rowsBetweenChecks == 0 {
+			if err := h.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		diagonal := 0 // row[j-1] of the previous row
+		for j := 1; j <= len(b); j++ {
+			above := row[j]
+			if id == b[j-1] {
+				row[j] = diagonal + 1
+			} else if row[j-1] > row[j] {
+				row[j] = row[j-1]
+			}
+			diagonal = above
+		}
+	}
+	return nil
+}
+
+// reverseLengths sets row[j] to the length of the LCS of a and b[j:].
+func (h *hirschberg) reverseLengths(a []int, b []int, row []int) error {
+	for j := 0; j <= len(b); j++ {
+		row[j] = 0
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		if (len(a)-1-i)%rowsBetweenChecks == 0 {
+			if err := h.ctx.Err(); err != nil {
+				return err
+			}
+		}
+		diagonal := 0 // row[j+1] of the previous row
+		for j := len(b) - 1; j >= 0; j-- {
+			below := row[j]
+			if a[i] == b[j] {
+				row[j] = diagonal + 1
+			} else if row[j+1] > row[j] {
+				row[j] = row[j+1]
+			}
+			diagonal = below
+		}
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package gojsondiff
 
 import (
 	"context"
+	"fmt"
+	"math/rand"
 	"reflect"
 	"testing"
 	"time"
@@ -119,3 +121,107 @@ func TestContextCancel(t *testing.T) {
 		t.Fatalf("unexpected err: %s", err)
 	}
 }
+
+func TestLinearLCS(t *testing.T) {
+	cases := []struct {
+		left   []interface{}
+		right  []interface{}
+		length int
+	}{
+		{left: []interface{}{1, 2, 3}, right: []interface{}{2, 3}, length: 2},
+		{left: []interface{}{2, 3}, right: []interface{}{1, 2, 3}, length: 2},
+		{left: []interface{}{2, 3, 3}, right: []interface{}{2, 5, 3}, length: 2},
+		{left: []interface{}{1, 2, 5, 3, 1, 1, 5, 8, 3}, right: []interface{}{1, 2, 3, 3, 4, 4, 5, 1, 6}, length: 4},
+		{left: []interface{}{}, right: []interface{}{2, 5, 3}, length: 0},
+		{left: []interface{}{3, 4}, right: []interface{}{}, length: 0},
+		{left: []interface{}{"foo"}, right: []interface{}{"baz", "foo"}, length: 1},
+		{left: []interface{}{"a", "b", "c", "d"}, right: []interface{}{"d", "c", "b", "a"}, length: 1},
+	}
+
+	for i, c := range cases {
+		lcs := NewLinearLCS(c.left, c.right)
+
+		actualLength := lcs.Length()
+		if actualLength != c.length {
+			t.Errorf("test case %d failed at length, actual: %d, expected: %d", i, actualLength, c.length)
+		}
+		if err := checkCommonSubsequence(c.left, c.right, lcs.IndexPairs()); err != nil {
+			t.Errorf("test case %d failed at index pair: %s", i, err)
+		}
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		left := randomItems(random, random.Intn(40))
+		right := randomItems(random, random.Intn(40))
+
+		expected := NewLCS(left, right).Length()
+		linear := NewLinearLCS(left, right)
+		if actual := linear.Length(); actual != expected {
+			t.Fatalf("random case %d failed at length, actual: %d, expected: %d, left: %v, right: %v", i, actual, expected, left, right)
+		}
+		if err := checkCommonSubsequence(left, right, linear.IndexPairs()); err != nil {
+			t.Fatalf("random case %d failed at index pair: %s", i, err)
+		}
+	}
+}
+
+func TestLinearLCSNestedValues(t *testing.T) {
+	left := []interface{}{
+		map[string]interface{}{"id": 1.0, "tags": []interface{}{"a"}},
+		map[string]interface{}{"id": 2.0},
+		1, // printed like 1.0, but not equal to it
+		[]interface{}{1.0, 2.0},
+	}
+	right := []interface{}{
+		map[string]interface{}{"tags": []interface{}{"a"}, "id": 1.0},
+		1.0,
+		[]interface{}{1.0, 2.0},
+	}
+	pairs := NewLinearLCS(left, right).IndexPairs()
+	if !reflect.DeepEqual(pairs, []IndexPair{{0, 0}, {3, 2}}) {
+		t.Errorf("actual: %#v", pairs)
+	}
+}
+
+func TestLinearLCSContextCancel(t *testing.T) {
+	left := make([]interface{}, 100000)
+	right := make([]interface{}, 100000)
+	right[0] = 1
+	right[len(right)-1] = 1
+	lcs := NewLinearLCS(left, right)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := lcs.LengthContext(ctx)
+	if err != context.Canceled {
+		t.Fatalf("unexpected err: %s", err)
+	}
+}
+
+// checkCommonSubsequence returns an error if the pairs are not increasing
+// indices of equal items.
+func checkCommonSubsequence(left, right []interface{}, pairs []IndexPair) error {
+	for i, pair := range pairs {
+		if !reflect.DeepEqual(left[pair.Left], right[pair.Right]) {
+			return fmt.Errorf("items of pair %#v differ", pair)
+		}
+		if i > 0 && (pair.Left <= pairs[i-1].Left || pair.Right <= pairs[i-1].Right) {
+			return fmt.Errorf("pair %#v does not follow %#v", pair, pairs[i-1])
+		}
+	}
+	return nil
+}
+
+func randomItems(random *rand.Rand, length int) []interface{} {
+	items := make([]interface{}, length)
+	for i := range items {
+		items[i] = random.Intn(5)
+	}
+	return items
+}
@@ -0,0 +1,94 @@
+package gojsondiff
+
+import (
+	"reflect"
+)
+
+// Ignore skips the values at the paths matched by the patterns, so that
+// changes of these values and of their children produce no delta.
+// The patterns are JSON Pointers where "*" matches any single token and "**"
+// matches any number of tokens, e.g. "/items/*/updatedAt" or "/**/requestId".
+// Ignore rules apply to object members; items of arrays are matched with
+// their members ignored.
+func Ignore(patterns ...string) Option {
+	parsed := make([]pathPattern, len(patterns))
+	for i, pattern := range patterns {
+		parsed[i] = mustParsePathPattern(pattern)
+	}
+	return func(differ *Differ) {
+		differ.ignored = append(differ.ignored, parsed...)
+	}
+}
+
+// ReportIgnored makes Diff.Ignored return the JSON Pointers of the values
+// skipped by Ignore rules whose left and right sides differ.
+func ReportIgnored() Option {
+	return func(differ *Differ) {
+		differ.reportIgnored = true
+	}
+}
+
+// isIgnored returns true if the value at the path is skipped.
+func (differ *Differ) isIgnored(path Path) bool {
+	for _, pattern := range differ.ignored {
+		if pattern.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoresBelow returns true if a descendant of the path may be skipped.
+func (differ *Differ) ignoresBelow(path Path) bool {
+	for _, pattern := range differ.ignored {
+		if pattern.matchBelow(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutIgnored returns the value without its skipped members. Only the
+// containers on the way to skipped members are copied.
+func (differ *Differ) withoutIgnored(path Path, value interface{}) interface{} {
+	if !differ.ignoresBelow(path) {
+		return value
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(typed))
+		for name, child := range typed {
+			childPath := path.child(Name(name))
+			if differ.isIgnored(childPath) {
+				continue
+			}
+			pruned[name] = differ.withoutIgnored(childPath, child)
+		}
+		return pruned
+	case []interface{}:
+		pruned := make([]interface{}, len(typed))
+		for i, child := range typed {
+			pruned[i] = differ.withoutIgnored(path.child(Index(i)), child)
+		}
+		return pruned
+	}
+	return value
+}
+
+// skipIgnored returns true if the value at the path is skipped and records
+// the path when the skipped values differ.
+func (c *comparison) skipIgnored(
+	path Path,
+	left interface{},
+	leftExists bool,
+	right interface{},
+	rightExists bool,
+) bool {
+	if !c.isIgnored(path) {
+		return false
+	}
+	if c.reportIgnored && (leftExists != rightExists || !reflect.DeepEqual(left, right)) {
+		c.ignored = append(c.ignored, path.String())
+	}
+	return true
+}
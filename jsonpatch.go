@@ -0,0 +1,674 @@
+package gojsondiff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Operations of a JSON Patch (RFC 6902).
+const (
+	JsonPatchAdd     = "add"
+	JsonPatchRemove  = "remove"
+	JsonPatchReplace = "replace"
+	JsonPatchMove    = "move"
+	JsonPatchCopy    = "copy"
+	JsonPatchTest    = "test"
+)
+
+// A JsonPatchOperation is an operation of a JSON Patch (RFC 6902).
+// Path and From are JSON Pointers (RFC 6901).
+type JsonPatchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value interface{}
+}
+
+// MarshalJSON emits the members of the operation required by its type,
+// so that null values are kept and unused members are left out.
+func (o JsonPatchOperation) MarshalJSON() ([]byte, error) {
+	switch o.Op {
+	case JsonPatchAdd, JsonPatchReplace, JsonPatchTest:
+		return json.Marshal(struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}{o.Op, o.Path, o.Value})
+	case JsonPatchMove, JsonPatchCopy:
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			From string `json:"from"`
+			Path string `json:"path"`
+		}{o.Op, o.From, o.Path})
+	}
+	return json.Marshal(struct {
+		Op   string `json:"op"`
+		Path string `json:"path"`
+	}{o.Op, o.Path})
+}
+
+// UnmarshalJSON parses an operation and checks that it has the members
+// required by its type.
+func (o *JsonPatchOperation) UnmarshalJSON(data []byte) error {
+	operation, err := parseJsonPatchOperation(data, false)
+	if err != nil {
+		return err
+	}
+	*o = operation
+	return nil
+}
+
+// A JsonPatchError reports an operation of a JSON Patch which is malformed
+// or can not be applied, e.g. a failing "test" operation.
+type JsonPatchError struct {
+	// Index is the index of the operation in the patch.
+	Index int
+	// Op is the type of the operation, empty when it is unknown.
+	Op string
+	// Pointer is the JSON Pointer the error is about, "path" or "from".
+	Pointer string
+	// Reason describes the error.
+	Reason string
+}
+
+func (e *JsonPatchError) Error() string {
+	return fmt.Sprintf("JSON Patch operation %d (%s `%s`): %s", e.Index, e.Op, e.Pointer, e.Reason)
+}
+
+// jsonPatchOperationError is returned by parseJsonPatchOperation, which does
+// not know the index of the operation.
+type jsonPatchOperationError struct {
+	op      string
+	pointer string
+	reason  string
+}
+
+func (e *jsonPatchOperationError) Error() string {
+	return fmt.Sprintf("invalid JSON Patch operation (%s `%s`): %s", e.op, e.pointer, e.reason)
+}
+
+// UnmarshalJsonPatch parses a JSON Patch (RFC 6902), an array of operations.
+// Malformed operations are reported by a *JsonPatchError.
+func (um *Unmarshaller) UnmarshalJsonPatch(patchBytes []byte) ([]JsonPatchOperation, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(patchBytes, &raws); err != nil {
+		return nil, err
+	}
+	operations := make([]JsonPatchOperation, len(raws))
+	for i, raw := range raws {
+		operation, err := parseJsonPatchOperation(raw, um.UseNumber)
+		if err != nil {
+			if invalid, ok := err.(*jsonPatchOperationError); ok {
+				return nil, &JsonPatchError{Index: i, Op: invalid.op, Pointer: invalid.pointer, Reason: invalid.reason}
+			}
+			return nil, &JsonPatchError{Index: i, Reason: err.Error()}
+		}
+		operations[i] = operation
+	}
+	return operations, nil
+}
+
+func parseJsonPatchOperation(data []byte, useNumber bool) (operation JsonPatchOperation, err error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return operation, err
+	}
+	invalid := func(pointer string, reason string) error {
+		return &jsonPatchOperationError{op: operation.Op, pointer: pointer, reason: reason}
+	}
+	pointer := func(name string) (string, error) {
+		raw, ok := members[name]
+		if !ok {
+			return "", invalid("", fmt.Sprintf("missing \"%s\"", name))
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return "", invalid("", fmt.Sprintf("\"%s\" is not a string", name))
+		}
+		if _, err := splitPointer(value); err != nil {
+			return "", invalid(value, err.Error())
+		}
+		return value, nil
+	}
+
+	raw, ok := members["op"]
+	if !ok {
+		return operation, invalid("", "missing \"op\"")
+	}
+	if err := json.Unmarshal(raw, &operation.Op); err != nil {
+		return operation, invalid("", "\"op\" is not a string")
+	}
+	if operation.Path, err = pointer("path"); err != nil {
+		return operation, err
+	}
+
+	switch operation.Op {
+	case JsonPatchAdd, JsonPatchReplace, JsonPatchTest:
+		raw, ok := members["value"]
+		if !ok {
+			return operation, invalid(operation.Path, "missing \"value\"")
+		}
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		if useNumber {
+			decoder.UseNumber()
+		}
+		if err := decoder.Decode(&operation.Value); err != nil {
+			return operation, invalid(operation.Path, err.Error())
+		}
+	case JsonPatchMove, JsonPatchCopy:
+		if operation.From, err = pointer("from"); err != nil {
+			return operation, err
+		}
+	case JsonPatchRemove:
+	default:
+		return operation, invalid(operation.Path, fmt.Sprintf("unknown operation \"%s\"", operation.Op))
+	}
+	return operation, nil
+}
+
+// ApplyJsonPatch applies the operations of a JSON Patch (RFC 6902) to a copy
+// of a JSON document and returns the patched copy. The values of "test"
+// operations are compared with the options of the Differ. The first
+// operation which can not be applied stops the patch with a *JsonPatchError.
+// Like ApplyPatch, the objects and arrays of the copy are normalized by the
+// normalizers of the Differ first, so that the JSON Patches formatted from
+// its Diffs apply.
+func (differ *Differ) ApplyJsonPatch(json interface{}, operations []JsonPatchOperation) (interface{}, error) {
+	patcher, err := differ.newJsonPatcher(differ.normalizeShape(Path{}, json), operations)
+	if err != nil {
+		return nil, err
+	}
+	return patcher.root.value(), nil
+}
+
+// JsonPatchDiff translates the operations of a JSON Patch (RFC 6902) applied
+// to a JSON document into a Diff of the document. Operations become Added,
+// Deleted and Modified deltas, values moved within an array become Moved
+// deltas and values moved between containers, as well as copies, become
+// Deleted and Added deltas. "test" operations are checked like ApplyJsonPatch
+// does, but leave no delta. Positions of the deltas refer to the document
+// normalized like ApplyJsonPatch does.
+func (differ *Differ) JsonPatchDiff(json interface{}, operations []JsonPatchOperation) (Diff, error) {
+	json = differ.normalizeShape(Path{}, json)
+	patcher, err := differ.newJsonPatcher(json, operations)
+	if err != nil {
+		return nil, err
+	}
+	root := patcher.root
+	if !root.original {
+		if patcher.equal(Path{}, json, root.value()) {
+			return &diff{deltas: []Delta{}}, nil
+		}
+		return &diff{deltas: []Delta{NewModified(Root{}, json, root.value())}}, nil
+	}
+	return &diff{deltas: patcher.nodeDeltas(Path{}, root)}, nil
+}
+
+// A jsonPatchNode is a value of a document patched by a JSON Patch.
+// Nodes which originate from the left side document remember their position
+// there, so that the changes made by the patch can be translated into deltas.
+type jsonPatchNode struct {
+	// members and items are the children of objects and arrays, scalar is
+	// the value of other nodes
+	members map[string]*jsonPatchNode
+	items   []*jsonPatchNode
+	scalar  interface{}
+
+	// original is true for the nodes of the left side document, whose value
+	// is left and position in their parent is position
+	original bool
+	left     interface{}
+	parent   *jsonPatchNode
+	position Position
+	// replaced is the original node replaced by the node, if any
+	replaced *jsonPatchNode
+}
+
+// newJsonPatchNode returns the node of a value, which originates from the
+// left side document if original is true.
+func newJsonPatchNode(value interface{}, original bool, parent *jsonPatchNode, position Position) *jsonPatchNode {
+	node := &jsonPatchNode{original: original, parent: parent, position: position}
+	if original {
+		node.left = value
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		node.members = make(map[string]*jsonPatchNode, len(typed))
+		for name, member := range typed {
+			node.members[name] = newJsonPatchNode(member, original, node, Name(name))
+		}
+	case []interface{}:
+		node.items = make([]*jsonPatchNode, len(typed))
+		for i, item := range typed {
+			node.items[i] = newJsonPatchNode(item, original, node, Index(i))
+		}
+	default:
+		node.scalar = value
+	}
+	return node
+}
+
+// value returns the JSON value of the node.
+func (node *jsonPatchNode) value() interface{} {
+	switch {
+	case node.members != nil:
+		object := make(map[string]interface{}, len(node.members))
+		for name, member := range node.members {
+			object[name] = member.value()
+		}
+		return object
+	case node.items != nil:
+		array := make([]interface{}, len(node.items))
+		for i, item := range node.items {
+			array[i] = item.value()
+		}
+		return array
+	}
+	return node.scalar
+}
+
+// kept returns true if the node is at its original position in the parent.
+func (node *jsonPatchNode) kept(parent *jsonPatchNode, position Position) bool {
+	return node.original && node.parent == parent && node.position == position
+}
+
+// A jsonPatcher applies the operations of a JSON Patch to the nodes of a document.
+type jsonPatcher struct {
+	*comparison
+	root *jsonPatchNode
+}
+
+func (differ *Differ) newJsonPatcher(json interface{}, operations []JsonPatchOperation) (*jsonPatcher, error) {
+	p := &jsonPatcher{
+		comparison: differ.newComparison(context.Background(), false),
+		root:       newJsonPatchNode(json, true, nil, Root{}),
+	}
+	for i, operation := range operations {
+		if err := p.apply(operation); err != nil {
+			err.Index = i
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// apply applies an operation, or returns the reason why it can not be applied.
+func (p *jsonPatcher) apply(operation JsonPatchOperation) *JsonPatchError {
+	fail := func(pointer string, reason string) *JsonPatchError {
+		return &JsonPatchError{Op: operation.Op, Pointer: pointer, Reason: reason}
+	}
+	path, err := splitPointer(operation.Path)
+	if err != nil {
+		return fail(operation.Path, err.Error())
+	}
+	reason := ""
+	switch operation.Op {
+	case JsonPatchAdd:
+		reason = p.add(path, newJsonPatchNode(deepCopy(operation.Value), false, nil, nil))
+	case JsonPatchRemove:
+		if len(path) == 0 {
+			return fail(operation.Path, "the whole document can not be removed")
+		}
+		_, reason = p.remove(path)
+	case JsonPatchReplace:
+		if len(path) == 0 {
+			p.root = p.replacing(p.root, operation.Value)
+			return nil
+		}
+		var removed *jsonPatchNode
+		if removed, reason = p.remove(path); reason == "" {
+			reason = p.add(path, p.replacing(removed, operation.Value))
+		}
+	case JsonPatchTest:
+		var node *jsonPatchNode
+		if node, reason = p.get(path); reason == "" && !p.equal(p.path(path), operation.Value, node.value()) {
+			reason = "test failed: the value differs"
+		}
+	case JsonPatchMove, JsonPatchCopy:
+		from, err := splitPointer(operation.From)
+		if err != nil {
+			return fail(operation.From, err.Error())
+		}
+		var node *jsonPatchNode
+		if node, reason = p.get(from); reason != "" {
+			return fail(operation.From, "\"from\": "+reason)
+		}
+		switch {
+		case operation.Op == JsonPatchCopy:
+			reason = p.add(path, newJsonPatchNode(node.value(), false, nil, nil))
+		case operation.From == operation.Path:
+		case len(from) == 0:
+			return fail(operation.From, "the whole document can not be moved")
+		case len(path) > len(from) && isPointerPrefix(from, path):
+			return fail(operation.Path, "a value can not be moved into one of its children")
+		default:
+			p.remove(from)
+			reason = p.add(path, node)
+		}
+	default:
+		reason = fmt.Sprintf("unknown operation \"%s\"", operation.Op)
+	}
+	if reason != "" {
+		return fail(operation.Path, reason)
+	}
+	return nil
+}
+
+// replacing returns the node of a value replacing the node.
+func (p *jsonPatcher) replacing(node *jsonPatchNode, value interface{}) *jsonPatchNode {
+	replacement := newJsonPatchNode(deepCopy(value), false, nil, nil)
+	replacement.replaced = node.replaced
+	if node.original {
+		replacement.replaced = node
+	}
+	return replacement
+}
+
+// get returns the node at the path.
+func (p *jsonPatcher) get(path []string) (*jsonPatchNode, string) {
+	node := p.root
+	for _, token := range path {
+		switch {
+		case node.members != nil:
+			member, ok := node.members[token]
+			if !ok {
+				return nil, "the value does not exist"
+			}
+			node = member
+		case node.items != nil:
+			index, reason := arrayIndex(token, len(node.items)-1)
+			if reason != "" {
+				return nil, reason
+			}
+			node = node.items[index]
+		default:
+			return nil, "the value does not exist"
+		}
+	}
+	return node, ""
+}
+
+// path returns the Path of the existing value at the reference tokens.
+func (p *jsonPatcher) path(tokens []string) Path {
+	path := make(Path, 0, len(tokens))
+	node := p.root
+	for _, token := range tokens {
+		if node.items != nil {
+			index, _ := strconv.Atoi(token)
+			path = append(path, Index(index))
+			node = node.items[index]
+		} else {
+			path = append(path, Name(token))
+			node = node.members[token]
+		}
+	}
+	return path
+}
+
+// add adds the node at the path, replacing the member of an object or
+// inserting the item of an array.
+func (p *jsonPatcher) add(path []string, node *jsonPatchNode) string {
+	if len(path) == 0 {
+		p.root = node
+		return ""
+	}
+	parent, reason := p.get(path[:len(path)-1])
+	if reason != "" {
+		return reason
+	}
+	token := path[len(path)-1]
+	switch {
+	case parent.members != nil:
+		parent.members[token] = node
+	case parent.items != nil:
+		index := len(parent.items)
+		if token != "-" {
+			if index, reason = arrayIndex(token, len(parent.items)); reason != "" {
+				return reason
+			}
+		}
+		parent.items = append(parent.items, nil)
+		copy(parent.items[index+1:], parent.items[index:])
+		parent.items[index] = node
+	default:
+		return "the parent is not an object or an array"
+	}
+	return ""
+}
+
+// remove removes the node at the path and returns it.
+func (p *jsonPatcher) remove(path []string) (*jsonPatchNode, string) {
+	parent, reason := p.get(path[:len(path)-1])
+	if reason != "" {
+		return nil, reason
+	}
+	token := path[len(path)-1]
+	switch {
+	case parent.members != nil:
+		node, ok := parent.members[token]
+		if !ok {
+			return nil, "the value does not exist"
+		}
+		delete(parent.members, token)
+		return node, ""
+	case parent.items != nil:
+		index, reason := arrayIndex(token, len(parent.items)-1)
+		if reason != "" {
+			return nil, reason
+		}
+		node := parent.items[index]
+		parent.items = append(parent.items[:index], parent.items[index+1:]...)
+		return node, ""
+	}
+	return nil, "the value does not exist"
+}
+
+// arrayIndex parses an array index which must not exceed max.
+func arrayIndex(token string, max int) (int, string) {
+	if token == "" || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Sprintf("invalid array index \"%s\"", token)
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 {
+		return 0, fmt.Sprintf("invalid array index \"%s\"", token)
+	}
+	if index > max {
+		return 0, fmt.Sprintf("array index %d out of range", index)
+	}
+	return index, ""
+}
+
+// isPointerPrefix returns true if the tokens of prefix start the tokens of path.
+func isPointerPrefix(prefix []string, path []string) bool {
+	for i, token := range prefix {
+		if path[i] != token {
+			return false
+		}
+	}
+	return true
+}
+
+// equal returns true if two values are the same for the Differ.
+func (p *jsonPatcher) equal(path Path, left interface{}, right interface{}) bool {
+	same, _ := p.compareValues(path, left, right)
+	return same
+}
+
+// nodeDeltas returns the deltas of the children of an original node.
+func (p *jsonPatcher) nodeDeltas(path Path, node *jsonPatchNode) []Delta {
+	switch left := node.left.(type) {
+	case map[string]interface{}:
+		return p.objectDeltas(path, node, left)
+	case []interface{}:
+		return p.arrayDeltas(path, node, left)
+	}
+	return []Delta{}
+}
+
+// nodeDelta returns the delta of the changes of an original node at the
+// position, if any.
+func (p *jsonPatcher) nodeDelta(path Path, node *jsonPatchNode, position Position) Delta {
+	deltas := p.nodeDeltas(path, node)
+	if len(deltas) == 0 {
+		return nil
+	}
+	if node.members != nil {
+		return NewObject(position, deltas)
+	}
+	return NewArray(position, deltas)
+}
+
+func (p *jsonPatcher) objectDeltas(path Path, node *jsonPatchNode, left map[string]interface{}) []Delta {
+	deltas := make([]Delta, 0)
+	names := make([]string, 0, len(left))
+	for name := range left {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	renames := p.renames(node, left)
+	for _, name := range names {
+		memberPath := path.child(Name(name))
+		member, ok := node.members[name]
+		newName, renamed := renames[name]
+		switch {
+		case !ok && renamed:
+			delta := p.nodeDelta(path.child(Name(newName)), node.members[newName], Name(newName))
+			deltas = append(deltas, NewMoved(Name(name), Name(newName), left[name], delta))
+		case !ok:
+			deltas = append(deltas, NewDeleted(Name(name), left[name]))
+		case member.kept(node, Name(name)):
+			if delta := p.nodeDelta(memberPath, member, Name(name)); delta != nil {
+				deltas = append(deltas, delta)
+			}
+		default:
+			if value := member.value(); !p.equal(memberPath, left[name], value) {
+				deltas = append(deltas, NewModified(Name(name), left[name], value))
+			}
+		}
+	}
+
+	names = names[:0]
+	for name := range node.members {
+		if _, ok := left[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		member := node.members[name]
+		if member.original && member.parent == node {
+			if newName, renamed := renames[member.position.String()]; renamed && newName == name {
+				continue
+			}
+		}
+		deltas = append(deltas, NewAdded(Name(name), member.value()))
+	}
+	return deltas
+}
+
+// renames returns the new names of the members of an object moved to another
+// name of the same object, by old name, when the Differ detects renames.
+// Members moved to a name of the left side object, or whose old name is
+// taken again, are deleted and added instead.
+func (p *jsonPatcher) renames(node *jsonPatchNode, left map[string]interface{}) map[string]string {
+	renames := make(map[string]string)
+	if p.renameSimilarity <= 0 {
+		return renames
+	}
+	for name, member := range node.members {
+		if _, ok := left[name]; ok || !member.original || member.parent != node {
+			continue
+		}
+		oldName := member.position.String()
+		if _, taken := node.members[oldName]; !taken {
+			renames[oldName] = name
+		}
+	}
+	return renames
+}
+
+// arrayDeltas returns the deltas of an array. Original items which keep
+// their order are kept, and are modified when they are replaced, other
+// original items are moved.
+func (p *jsonPatcher) arrayDeltas(path Path, node *jsonPatchNode, left []interface{}) []Delta {
+	// pre indexes of the items at their post index, -1 for added items
+	pres := make([]int, len(node.items))
+	present := make(map[int]bool)
+	for j, item := range node.items {
+		pres[j] = -1
+		origin := item
+		if !item.original {
+			origin = item.replaced
+		}
+		if origin != nil && origin.original && origin.parent == node {
+			pres[j] = int(origin.position.(Index))
+			present[pres[j]] = true
+		}
+	}
+	kept := longestIncreasing(pres)
+
+	deltas := make([]Delta, 0)
+	for i, item := range left {
+		if !present[i] {
+			deltas = append(deltas, NewDeleted(Index(i), item))
+		}
+	}
+	for j, item := range node.items {
+		itemPath := path.child(Index(j))
+		i := pres[j]
+		switch {
+		case i < 0:
+			deltas = append(deltas, NewAdded(Index(j), item.value()))
+		case !item.original && kept[j]:
+			if value := item.value(); !p.equal(itemPath, left[i], value) {
+				deltas = append(deltas, NewModified(Index(j), left[i], value))
+			}
+		case !item.original:
+			// a replaced item which is not in place
+			deltas = append(deltas, NewDeleted(Index(i), left[i]), NewAdded(Index(j), item.value()))
+		case kept[j]:
+			if delta := p.nodeDelta(itemPath, item, Index(j)); delta != nil {
+				deltas = append(deltas, delta)
+			}
+		default:
+			deltas = append(deltas, NewMoved(Index(i), Index(j), left[i], p.nodeDelta(itemPath, item, Index(j))))
+		}
+	}
+	return deltas
+}
+
+// longestIncreasing returns the positions of a longest increasing
+// subsequence of the non-negative values.
+func longestIncreasing(values []int) []bool {
+	// tails[k] is the position of the smallest tail of the subsequences of length k+1
+	tails := make([]int, 0)
+	previous := make([]int, len(values))
+	for j, value := range values {
+		if value < 0 {
+			continue
+		}
+		k := sort.Search(len(tails), func(k int) bool { return values[tails[k]] >= value })
+		if k > 0 {
+			previous[j] = tails[k-1]
+		} else {
+			previous[j] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, j)
+		} else {
+			tails[k] = j
+		}
+	}
+
+	result := make([]bool, len(values))
+	if len(tails) > 0 {
+		for j := tails[len(tails)-1]; j >= 0; j = previous[j] {
+			result[j] = true
+		}
+	}
+	return result
+}
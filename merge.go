@@ -0,0 +1,525 @@
+package gojsondiff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A MergeResolution tells which side of a conflict Merge keeps.
+type MergeResolution int
+
+const (
+	// ResolveFail keeps the value of the base document and makes Merge
+	// return a *MergeError.
+	ResolveFail MergeResolution = iota
+	// ResolveOurs keeps the change of our side.
+	ResolveOurs
+	// ResolveTheirs keeps the change of their side.
+	ResolveTheirs
+)
+
+func (r MergeResolution) String() string {
+	switch r {
+	case ResolveOurs:
+		return "ours"
+	case ResolveTheirs:
+		return "theirs"
+	}
+	return "fail"
+}
+
+// A MergeStrategy resolves the conflicts of a Merge, e.g. depending on
+// their path.
+type MergeStrategy func(conflict MergeConflict) MergeResolution
+
+var (
+	// MergeOurs resolves every conflict with our change.
+	MergeOurs MergeStrategy = func(MergeConflict) MergeResolution { return ResolveOurs }
+	// MergeTheirs resolves every conflict with their change.
+	MergeTheirs MergeStrategy = func(MergeConflict) MergeResolution { return ResolveTheirs }
+	// MergeFail leaves every conflict unresolved.
+	MergeFail MergeStrategy = func(MergeConflict) MergeResolution { return ResolveFail }
+)
+
+// A MergeConflict is a value changed differently by both sides of a Merge.
+type MergeConflict struct {
+	// Path is the JSON Pointer of the value in the base document.
+	Path string
+	// Ours and Theirs are the conflicting deltas of each side, relative to
+	// the base document.
+	Ours   Delta
+	Theirs Delta
+	// Resolution is the resolution chosen by the MergeStrategy.
+	Resolution MergeResolution
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%T and %T at `%s`", c.Ours, c.Theirs, c.Path)
+}
+
+// A MergeError is returned by Merge when conflicts are left unresolved.
+type MergeError struct {
+	Conflicts []MergeConflict
+}
+
+func (e *MergeError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.String()
+	}
+	return fmt.Sprintf("%d unresolved conflict(s) merging the documents: %s", len(e.Conflicts), strings.Join(messages, "; "))
+}
+
+// Merge merges the changes made by two sides to a base document, e.g. a
+// local copy and an upstream update. Both sides are compared to the base with
+// the options of the Differ and the changes of different values are
+// combined: members of objects, and items of arrays, whose indexes are
+// rebased on the changes of the other side.
+// Values changed differently by both sides are conflicts, which are all
+// returned along with the merged document, and resolved by the strategy.
+// Conflicts resolved with ResolveFail keep their base value and are reported
+// by a *MergeError. A nil strategy is MergeFail.
+// The documents are left untouched. Like ApplyPatch, the objects and arrays of
+// the merged document are normalized by the normalizers of the Differ.
+func (differ *Differ) Merge(base, ours, theirs interface{}, strategy MergeStrategy) (merged interface{}, conflicts []MergeConflict, err error) {
+	if strategy == nil {
+		strategy = MergeFail
+	}
+	m := &merger{
+		comparison: differ.newComparison(context.Background(), false),
+		strategy:   strategy,
+	}
+	ourDelta := wholeDelta(differ.CompareValues(base, ours).Deltas())
+	theirDelta := wholeDelta(differ.CompareValues(base, theirs).Deltas())
+	// the positions of the deltas refer to the normalized base
+	base = differ.normalizeShape(Path{}, base)
+	deltas := partDeltas(m.mergeDelta(Path{}, Root{}, base, ourDelta, theirDelta))
+	// like Patch, the patcher copies the values of the sides it inserts, so
+	// that the merged document shares nothing with the documents
+	p := &patcher{comparison: m.comparison}
+	p.normalizedShapes = true
+	merged = p.applyDeltas(Path{}, deepCopy(base), deltas)
+
+	var unresolved []MergeConflict
+	for _, conflict := range m.conflicts {
+		if conflict.Resolution == ResolveFail {
+			unresolved = append(unresolved, conflict)
+		}
+	}
+	if len(unresolved) > 0 {
+		return merged, m.conflicts, &MergeError{Conflicts: unresolved}
+	}
+	return merged, m.conflicts, nil
+}
+
+// wholeDelta returns the deltas of a document as a single Delta at the Root
+// position, or nil when there is none.
+func wholeDelta(deltas []Delta) Delta {
+	switch {
+	case len(deltas) == 0:
+		return nil
+	case len(deltas) == 1 && isRoot(deltaPosition(deltas[0])):
+		return deltas[0]
+	case isArrayDeltas(deltas):
+		return NewArray(Root{}, deltas)
+	}
+	return NewObject(Root{}, deltas)
+}
+
+// partDeltas reverses wholeDelta.
+func partDeltas(delta Delta) []Delta {
+	switch d := delta.(type) {
+	case nil:
+		return []Delta{}
+	case *Object:
+		return d.Deltas
+	case *Array:
+		return d.Deltas
+	}
+	return []Delta{delta}
+}
+
+// deltaPosition returns the position of a delta, the post position of
+// deltas which have both.
+func deltaPosition(delta Delta) Position {
+	if post, ok := delta.(PostDelta); ok {
+		return post.PostPosition()
+	}
+	return delta.(PreDelta).PrePosition()
+}
+
+// A merger holds the state of a Merge.
+type merger struct {
+	*comparison
+	strategy  MergeStrategy
+	conflicts []MergeConflict
+}
+
+// conflict records and resolves a conflict.
+func (m *merger) conflict(path Path, ours Delta, theirs Delta) MergeResolution {
+	conflict := MergeConflict{Path: path.String(), Ours: ours, Theirs: theirs}
+	conflict.Resolution = m.strategy(conflict)
+	m.conflicts = append(m.conflicts, conflict)
+	return conflict.Resolution
+}
+
+// mergeDelta merges the deltas of both sides for the base value at the path
+// and returns the merged Delta at the position, nil when there is no change.
+func (m *merger) mergeDelta(path Path, position Position, base interface{}, ours Delta, theirs Delta) Delta {
+	switch {
+	case ours == nil:
+		return repositioned(theirs, position)
+	case theirs == nil:
+		return repositioned(ours, position)
+	}
+	// deltas of the members and items of a value which is not an object or an
+	// array are conflicts
+	switch o := ours.(type) {
+	case *Object:
+		t, ok := theirs.(*Object)
+		if object, isObject := base.(map[string]interface{}); ok && isObject {
+			deltas := m.mergeObject(path, object, o.Deltas, t.Deltas)
+			if len(deltas) == 0 {
+				return nil
+			}
+			return NewObject(position, deltas)
+		}
+	case *Array:
+		t, ok := theirs.(*Array)
+		if array, isArray := base.([]interface{}); ok && isArray {
+			deltas := m.mergeArray(path, array, o.Deltas, t.Deltas)
+			if len(deltas) == 0 {
+				return nil
+			}
+			return NewArray(position, deltas)
+		}
+	}
+	if m.sameChange(path, ours, theirs) {
+		return repositioned(ours, position)
+	}
+	switch m.conflict(path, ours, theirs) {
+	case ResolveOurs:
+		return repositioned(ours, position)
+	case ResolveTheirs:
+		return repositioned(theirs, position)
+	}
+	return nil
+}
+
+// sameChange returns true if both deltas delete the value or replace it with
+// the same value.
+func (m *merger) sameChange(path Path, ours Delta, theirs Delta) bool {
+	_, ourDeletion := ours.(*Deleted)
+	_, theirDeletion := theirs.(*Deleted)
+	if ourDeletion || theirDeletion {
+		return ourDeletion && theirDeletion
+	}
+	ourValue, ok := newValue(ours)
+	if !ok {
+		return false
+	}
+	theirValue, ok := newValue(theirs)
+	if !ok {
+		return false
+	}
+	same, _ := m.compareValues(path, ourValue, theirValue)
+	return same
+}
+
+// newValue returns the value set by a delta which replaces a whole value.
+func newValue(delta Delta) (interface{}, bool) {
+	switch d := delta.(type) {
+	case *Added:
+		return d.Value, true
+	case *Modified:
+		return d.NewValue, true
+	case *TextDiff:
+		return d.NewValue, true
+	case *TypeChanged:
+		return d.NewValue, true
+	}
+	return nil, false
+}
+
+// repositioned returns a delta changing the same value as the delta at
+// another position, e.g. the index of an item in the merged array.
+func repositioned(delta Delta, position Position) Delta {
+	switch d := delta.(type) {
+	case nil:
+		return nil
+	case *Object:
+		return NewObject(position, d.Deltas)
+	case *Array:
+		return NewArray(position, d.Deltas)
+	case *Added:
+		return NewAdded(position, d.Value)
+	case *Modified:
+		return NewModified(position, d.OldValue, d.NewValue)
+	case *TextDiff:
+		return NewTextDiff(position, d.Diff, d.OldValue, d.NewValue)
+	case *TypeChanged:
+		return NewTypeChanged(position, d.OldValue, d.NewValue)
+	case *Deleted:
+		return NewDeleted(position, d.Value)
+	}
+	return delta
+}
+
+// mergeObject merges the deltas of the members of an object.
+func (m *merger) mergeObject(path Path, base map[string]interface{}, ours []Delta, theirs []Delta) []Delta {
+	// renamed members are merged as deleted and added members, the values
+	// of which are held by the deltas of the Differ
+	ours, _ = expandRenames(ours)
+	theirs, _ = expandRenames(theirs)
+	byName := func(deltas []Delta) map[string]Delta {
+		named := make(map[string]Delta, len(deltas))
+		for _, delta := range deltas {
+			named[deltaPosition(delta).String()] = delta
+		}
+		return named
+	}
+	ourDeltas, theirDeltas := byName(ours), byName(theirs)
+	names := make([]string, 0, len(ourDeltas)+len(theirDeltas))
+	for name := range ourDeltas {
+		names = append(names, name)
+	}
+	for name := range theirDeltas {
+		if _, ok := ourDeltas[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	deltas := make([]Delta, 0, len(names))
+	for _, name := range names {
+		delta := m.mergeDelta(path.child(Name(name)), Name(name), base[name], ourDeltas[name], theirDeltas[name])
+		if delta != nil {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas
+}
+
+// A mergeSide holds the changes of an array made by a side of a Merge, by
+// base index for the items of the base array.
+type mergeSide struct {
+	// deleted and moved hold the Deleted and Moved deltas of base items
+	deleted map[int]*Deleted
+	moved   map[int]*Moved
+	// changes holds the deltas changing base items which are not moved
+	changes map[int]Delta
+	// inserts holds the Added and Moved deltas of the items inserted after
+	// the base item at the index, in order, -1 for the start of the array
+	inserts map[int][]Delta
+}
+
+func newMergeSide(deltas []Delta) *mergeSide {
+	side := &mergeSide{
+		deleted: make(map[int]*Deleted),
+		moved:   make(map[int]*Moved),
+		changes: make(map[int]Delta),
+		inserts: make(map[int][]Delta),
+	}
+	indexes := newArrayIndexMap(deltas)
+	inserted := make(map[int]bool)
+	for _, index := range indexes.inserted {
+		inserted[index] = true
+	}
+
+	posts := make(postDeltas, 0, len(deltas))
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Deleted:
+			side.deleted[int(d.PrePosition().(Index))] = d
+		case *Moved:
+			side.moved[int(d.PrePosition().(Index))] = d
+			posts = append(posts, d)
+		case *Added:
+			posts = append(posts, d)
+		default:
+			post := int(delta.(PostDelta).PostPosition().(Index))
+			side.changes[indexes.preIndex(post)] = delta
+		}
+	}
+	sort.Stable(posts)
+	for _, delta := range posts {
+		// the insert follows the closest kept item
+		anchor := -1
+		for post := int(delta.PostPosition().(Index)) - 1; post >= 0; post-- {
+			if !inserted[post] {
+				anchor = indexes.preIndex(post)
+				break
+			}
+		}
+		side.inserts[anchor] = append(side.inserts[anchor], delta.(Delta))
+	}
+	return side
+}
+
+// anchorOf returns the base index the moved item follows.
+func (side *mergeSide) anchorOf(moved *Moved) int {
+	for anchor, deltas := range side.inserts {
+		for _, delta := range deltas {
+			if delta == Delta(moved) {
+				return anchor
+			}
+		}
+	}
+	return -1
+}
+
+// A mergeItem is an item of a merged array, a base item or an added value.
+type mergeItem struct {
+	base   int // -1 for added values
+	value  interface{}
+	change Delta
+}
+
+// mergeArray merges the deltas of the items of an array, and returns the
+// deltas of the merged array.
+func (m *merger) mergeArray(path Path, base []interface{}, ours []Delta, theirs []Delta) []Delta {
+	ourSide, theirSide := newMergeSide(ours), newMergeSide(theirs)
+
+	// resolve the fate of each base item
+	deleted := make(map[int]bool)
+	movedBy := make(map[int]*mergeSide) // the side whose move is kept
+	changes := make(map[int]Delta)
+	for i := range base {
+		itemPath := path.child(Index(i))
+		ourDeletion, theirDeletion := ourSide.deleted[i], theirSide.deleted[i]
+		ourMove, theirMove := ourSide.moved[i], theirSide.moved[i]
+		ourChange, theirChange := ourSide.changes[i], theirSide.changes[i]
+		if ourMove != nil {
+			ourChange, _ = ourMove.Delta.(Delta)
+		}
+		if theirMove != nil {
+			theirChange, _ = theirMove.Delta.(Delta)
+		}
+
+		switch {
+		case ourDeletion != nil && theirDeletion != nil:
+			deleted[i] = true
+			continue
+		case ourDeletion != nil && theirChange == nil, theirDeletion != nil && ourChange == nil:
+			// deleted by one side, left unchanged or only moved by the other one
+			deleted[i] = true
+			continue
+		case ourDeletion != nil || theirDeletion != nil:
+			ourDelta, theirDelta := sideDelta(ourDeletion, ourMove, ourChange), sideDelta(theirDeletion, theirMove, theirChange)
+			switch m.conflict(itemPath, ourDelta, theirDelta) {
+			case ResolveOurs:
+				deleted[i] = ourDeletion != nil
+				if ourMove != nil {
+					movedBy[i] = ourSide
+				}
+				changes[i] = ourChange
+			case ResolveTheirs:
+				deleted[i] = theirDeletion != nil
+				if theirMove != nil {
+					movedBy[i] = theirSide
+				}
+				changes[i] = theirChange
+			}
+			continue
+		case ourMove != nil && theirMove != nil && ourSide.anchorOf(ourMove) != theirSide.anchorOf(theirMove):
+			switch m.conflict(itemPath, ourMove, theirMove) {
+			case ResolveOurs:
+				movedBy[i] = ourSide
+			case ResolveTheirs:
+				movedBy[i] = theirSide
+			}
+		case ourMove != nil:
+			movedBy[i] = ourSide
+		case theirMove != nil:
+			movedBy[i] = theirSide
+		}
+		changes[i] = m.mergeDelta(itemPath, Index(i), base[i], ourChange, theirChange)
+	}
+
+	// lay out the merged array, inserts follow their anchor
+	items := make([]mergeItem, 0, len(base))
+	insert := func(anchor int) {
+		var added []interface{}
+		for _, side := range []*mergeSide{ourSide, theirSide} {
+			for _, delta := range side.inserts[anchor] {
+				switch d := delta.(type) {
+				case *Added:
+					if side == ourSide {
+						added = append(added, d.Value)
+					} else if k := m.indexOfValue(path, added, d.Value); k >= 0 {
+						// added by both sides
+						added = append(added[:k], added[k+1:]...)
+						continue
+					}
+					items = append(items, mergeItem{base: -1, value: d.Value})
+				case *Moved:
+					i := int(d.PrePosition().(Index))
+					if movedBy[i] == side && !deleted[i] {
+						items = append(items, mergeItem{base: i, change: changes[i]})
+					}
+				}
+			}
+		}
+	}
+	insert(-1)
+	for i := range base {
+		if !deleted[i] && movedBy[i] == nil {
+			items = append(items, mergeItem{base: i, change: changes[i]})
+		}
+		insert(i)
+	}
+
+	// items whose base indexes increase are kept, the other ones are moved
+	bases := make([]int, len(items))
+	present := make(map[int]bool)
+	for j, item := range items {
+		bases[j] = item.base
+		if item.base >= 0 {
+			present[item.base] = true
+		}
+	}
+	kept := longestIncreasing(bases)
+
+	deltas := make([]Delta, 0)
+	for i, value := range base {
+		if !present[i] {
+			deltas = append(deltas, NewDeleted(Index(i), value))
+		}
+	}
+	for j, item := range items {
+		switch {
+		case item.base < 0:
+			deltas = append(deltas, NewAdded(Index(j), item.value))
+		case kept[j]:
+			if item.change != nil {
+				deltas = append(deltas, repositioned(item.change, Index(j)))
+			}
+		default:
+			deltas = append(deltas, NewMoved(Index(item.base), Index(j), base[item.base], repositioned(item.change, Index(j))))
+		}
+	}
+	return deltas
+}
+
+// sideDelta returns the delta of a base item made by a side, if any.
+func sideDelta(deleted *Deleted, moved *Moved, change Delta) Delta {
+	switch {
+	case deleted != nil:
+		return deleted
+	case moved != nil:
+		return moved
+	}
+	return change
+}
+
+// indexOfValue returns the index of the first value equal to the value, or -1.
+func (m *merger) indexOfValue(path Path, values []interface{}, value interface{}) int {
+	for k, candidate := range values {
+		if same, _ := m.compareValues(path, candidate, value); same {
+			return k
+		}
+	}
+	return -1
+}
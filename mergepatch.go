@@ -0,0 +1,88 @@
+package gojsondiff
+
+import (
+	"context"
+	"sort"
+)
+
+// ApplyMergePatch applies a JSON Merge Patch (RFC 7386) to a copy of a JSON
+// document and returns the patched copy. Members of the patch which are null
+// remove the members of the document, other members are merged when both
+// values are objects and replace the values of the document otherwise.
+func (differ *Differ) ApplyMergePatch(json interface{}, patch interface{}) interface{} {
+	return applyMergePatch(json, patch)
+}
+
+func applyMergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return deepCopy(patch)
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	result := make(map[string]interface{}, len(targetObject))
+	for name, value := range targetObject {
+		result[name] = deepCopy(value)
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(result, name)
+		} else {
+			result[name] = applyMergePatch(result[name], value)
+		}
+	}
+	return result
+}
+
+// MergePatchDiff translates a JSON Merge Patch (RFC 7386) applied to a JSON
+// document into a Diff of the document. Null members become Deleted deltas
+// of the existing members, objects merged into objects become Object deltas
+// and other values are compared with the values they replace, so that e.g.
+// arrays, which are replaced as a whole by merge patches, get array deltas.
+func (differ *Differ) MergePatchDiff(json interface{}, patch interface{}) Diff {
+	c := differ.newComparison(context.Background(), false)
+	target, isObject := json.(map[string]interface{})
+	patchObject, isObjectPatch := patch.(map[string]interface{})
+	if !isObject || !isObjectPatch {
+		return c.newDiff(c.compareRoots(json, applyMergePatch(json, patch)))
+	}
+	return c.newDiff(c.mergePatchDeltas(Path{}, target, patchObject))
+}
+
+// mergePatchDeltas returns the deltas of an object patched by a merge patch.
+func (c *comparison) mergePatchDeltas(path Path, target map[string]interface{}, patch map[string]interface{}) []Delta {
+	names := make([]string, 0, len(patch))
+	for name := range patch {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	deltas := make([]Delta, 0)
+	for _, name := range names {
+		value := patch[name]
+		old, exists := target[name]
+		switch {
+		case value == nil:
+			if exists {
+				deltas = append(deltas, NewDeleted(Name(name), old))
+			}
+		case !exists:
+			deltas = append(deltas, NewAdded(Name(name), applyMergePatch(nil, value)))
+		default:
+			oldObject, isObject := old.(map[string]interface{})
+			patchObject, isObjectPatch := value.(map[string]interface{})
+			if isObject && isObjectPatch {
+				if childDeltas := c.mergePatchDeltas(path.child(Name(name)), oldObject, patchObject); len(childDeltas) > 0 {
+					deltas = append(deltas, NewObject(Name(name), childDeltas))
+				}
+				continue
+			}
+			if same, delta := c.compareValues(path.child(Name(name)), old, applyMergePatch(old, value)); !same {
+				deltas = append(deltas, delta)
+			}
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,61 @@
+package gojsondiff
+
+import "container/list"
+
+// DetectMoves makes a Differ report array items which are both moved and
+// changed: an item deleted from an array and an item added to another place
+// of it whose values have at least the given similarity produce a Moved delta
+// holding the changes of the item, instead of a Deleted and an Added delta.
+// Items replaced at the same place are still reported as modified, and items
+// matched by ArrayKeys are only paired by their keys.
+// Similarities are the ones used to match array items, e.g. 0.8 for objects
+// sharing most of their members; the most similar pairs are paired first.
+// A similarity of 0 or less disables the detection.
+func DetectMoves(similarity float64) Option {
+	if similarity > 1 {
+		similarity = 1
+	}
+	return func(differ *Differ) {
+		differ.moveSimilarity = similarity
+	}
+}
+
+// detectMoves pairs the items which are maybe deleted and maybe added at
+// other places of the array at the path, removes them from the lists and
+// returns their Moved deltas.
+func (c *comparison) detectMoves(path Path, maybeDeleted *list.List, maybeAdded *list.List) []Delta {
+	deltas := make([]Delta, 0)
+	if maybeDeleted.Len() == 0 || maybeAdded.Len() == 0 {
+		return deltas
+	}
+	// too many pairs to compare, the items are reported as deleted and added
+	if c.exceeded(BudgetSimilarityCells, path, c.maxSimilarityCells, maybeDeleted.Len()*maybeAdded.Len()) {
+		return deltas
+	}
+
+	var deleted, added []*list.Element
+	for element := maybeDeleted.Front(); element != nil; element = element.Next() {
+		deleted = append(deleted, element)
+	}
+	for element := maybeAdded.Front(); element != nil; element = element.Next() {
+		added = append(added, element)
+	}
+	pairs := c.pairSimilarValues(len(deleted), len(added), c.moveSimilarity, func(d, a int) (Path, interface{}, interface{}, bool) {
+		del, add := deleted[d].Value.(maybe), added[a].Value.(maybe)
+		_, deletedKeyed := del.token.(arrayKey)
+		_, addedKeyed := add.token.(arrayKey)
+		// items between the same kept items are modified in place
+		if deletedKeyed || addedKeyed || add.lcsIndex == del.lcsIndex {
+			return nil, nil, nil, false
+		}
+		return path.child(Index(add.index)), del.item, add.item, true
+	})
+
+	for _, pair := range pairs {
+		del, add := deleted[pair.deleted].Value.(maybe), added[pair.added].Value.(maybe)
+		deltas = append(deltas, NewMoved(Index(del.index), Index(add.index), c.recorded(path.child(Index(del.index)), del.item), pair.delta))
+		maybeDeleted.Remove(deleted[pair.deleted])
+		maybeAdded.Remove(added[pair.added])
+	}
+	return deltas
+}
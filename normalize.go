@@ -0,0 +1,262 @@
+package gojsondiff
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/text/unicode/norm"
+)
+
+// A Normalizer returns the value to compare in place of a value, e.g. the
+// value of a string without its surrounding spaces. It must not modify the
+// value and returns values of other types as they are.
+// A Normalizer only normalizes the value itself: the members and items of the
+// containers it returns are normalized in turn when they are compared.
+type Normalizer func(value interface{}) interface{}
+
+type normalizerRule struct {
+	pattern    pathPattern
+	normalizer Normalizer
+}
+
+// Normalize normalizes the values at the paths matched by the pattern with
+// the normalizers, in order, before comparing them, e.g.
+// Normalize("/**", TrimSpace, NFC). The pattern is a JSON Pointer where "*"
+// matches any single token and "**" matches any number of tokens.
+// Values are normalized on the fly as the documents are compared, which are
+// never copied as a whole. Positions of the deltas refer to the normalized
+// documents, e.g. to sorted arrays, while their values are the original ones
+// unless RecordNormalized is given. ApplyPatch, Patch, Merge and
+// ApplyJsonPatch normalize the objects and arrays of the documents they patch
+// with the same normalizers, so that the Diff applies: the patched documents
+// are normalized, e.g. with sorted arrays, while their other values are left
+// as they are.
+func Normalize(pattern string, normalizers ...Normalizer) Option {
+	parsed := mustParsePathPattern(pattern)
+	return func(differ *Differ) {
+		for _, normalizer := range normalizers {
+			differ.normalizers = append(differ.normalizers, normalizerRule{pattern: parsed, normalizer: normalizer})
+		}
+	}
+}
+
+// RecordNormalized makes the deltas hold the normalized values instead of the
+// original values of the documents.
+func RecordNormalized() Option {
+	return func(differ *Differ) {
+		differ.recordNormalized = true
+	}
+}
+
+// TrimSpace removes the leading and trailing white space of strings.
+func TrimSpace(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		return strings.TrimSpace(text)
+	}
+	return value
+}
+
+// NFC applies the Unicode Normalization Form C to strings, so that composed
+// and decomposed characters are the same.
+func NFC(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		return norm.NFC.String(text)
+	}
+	return value
+}
+
+// LowercaseKeys lowercases the names of the members of objects. When several
+// names have the same lowercase form, the member whose name comes first in
+// byte order is kept.
+func LowercaseKeys(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	lowercased := make(map[string]interface{}, len(object))
+	for _, name := range sortedKeys(object) {
+		lower := strings.ToLower(name)
+		if _, exists := lowercased[lower]; !exists {
+			lowercased[lower] = object[name]
+		}
+	}
+	return lowercased
+}
+
+// SortArray sorts the items of arrays by their JSON encoding, so that arrays
+// holding the same items in different orders are the same. Items are sorted
+// as they are, before their own normalization.
+func SortArray(value interface{}) interface{} {
+	array, ok := value.([]interface{})
+	if !ok {
+		return value
+	}
+	keys := make([]string, len(array))
+	for i, item := range array {
+		encoded, _ := json.Marshal(item)
+		keys[i] = string(encoded)
+	}
+	indexes := make([]int, len(array))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return keys[indexes[i]] < keys[indexes[j]] })
+	sorted := make([]interface{}, len(array))
+	for i, index := range indexes {
+		sorted[i] = array[index]
+	}
+	return sorted
+}
+
+// DropEmptyObjects removes the members of objects whose values are empty
+// objects, or objects whose members are all such empty objects.
+func DropEmptyObjects(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	dropped := make(map[string]interface{}, len(object))
+	for name, child := range object {
+		if !isEmptyObject(child) {
+			dropped[name] = child
+		}
+	}
+	return dropped
+}
+
+// isEmptyObject returns true if the value is an object without members other
+// than empty objects.
+func isEmptyObject(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for _, child := range object {
+		if !isEmptyObject(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns the value at the path normalized by the normalizers of
+// the path, its members and items are left as they are.
+func (differ *Differ) normalize(path Path, value interface{}) interface{} {
+	for _, rule := range differ.normalizers {
+		if rule.pattern.match(path) {
+			value = rule.normalizer(value)
+		}
+	}
+	return value
+}
+
+// normalizesBelow returns true if a descendant of the path may be normalized.
+func (differ *Differ) normalizesBelow(path Path) bool {
+	for _, rule := range differ.normalizers {
+		if rule.pattern.matchBelow(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDeep returns the value at the path with its members and items
+// normalized too. Only the containers on the way to normalized values are
+// copied.
+func (differ *Differ) normalizeDeep(path Path, value interface{}) interface{} {
+	return differ.normalizeTree(path, value, true, true)
+}
+
+// normalizeShape returns the value at the path with its objects and arrays
+// normalized, e.g. with lowercased names and sorted items, while its other
+// values are left as they are. Positions of the deltas refer to the shapes of
+// the normalized documents, which patches are applied to.
+func (differ *Differ) normalizeShape(path Path, value interface{}) interface{} {
+	return differ.normalizeTree(path, value, true, false)
+}
+
+// normalizeTree returns the value at the path with the values of its tree
+// normalized: containers are objects and arrays, and scalars the other values.
+func (differ *Differ) normalizeTree(path Path, value interface{}, containers bool, scalars bool) interface{} {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		if containers {
+			value = differ.normalize(path, value)
+		}
+	default:
+		if scalars {
+			value = differ.normalize(path, value)
+		}
+	}
+	if !differ.normalizesBelow(path) {
+		return value
+	}
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(typed))
+		for name, child := range typed {
+			normalized[name] = differ.normalizeTree(path.child(Name(name)), child, containers, scalars)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(typed))
+		for i, child := range typed {
+			normalized[i] = differ.normalizeTree(path.child(Index(i)), child, containers, scalars)
+		}
+		return normalized
+	}
+	return value
+}
+
+// normalize returns the value at the path normalized like Differ.normalize
+// does, but leaves the objects and arrays of comparisons between values whose
+// shapes are already normalized as they are.
+func (c *comparison) normalize(path Path, value interface{}) interface{} {
+	if c.normalizedShapes {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return value
+		}
+	}
+	return c.Differ.normalize(path, value)
+}
+
+// normalizeDeep returns the value at the path normalized like
+// Differ.normalizeDeep does, but leaves the objects and arrays of comparisons
+// between values whose shapes are already normalized as they are.
+func (c *comparison) normalizeDeep(path Path, value interface{}) interface{} {
+	return c.normalizeTree(path, value, !c.normalizedShapes, true)
+}
+
+// recorded returns the value at the path to hold in a delta.
+func (differ *Differ) recorded(path Path, value interface{}) interface{} {
+	if !differ.recordNormalized || len(differ.normalizers) == 0 {
+		return value
+	}
+	return differ.normalizeDeep(path, value)
+}
+
+// recordValues returns the delta of the normalized values at the path holding
+// the recorded values of left and right instead. Text diffs apply to the
+// original text of the left side, which the patched documents hold, and are
+// replaced by Modified deltas when the recorded left side differs from it.
+func (differ *Differ) recordValues(path Path, delta Delta, left interface{}, right interface{}) Delta {
+	switch d := delta.(type) {
+	case *Modified:
+		d.OldValue, d.NewValue = differ.recorded(path, left), differ.recorded(path, right)
+	case *TypeChanged:
+		d.OldValue, d.NewValue = differ.recorded(path, left), differ.recorded(path, right)
+	case *TextDiff:
+		oldValue, newValue := differ.recorded(path, left), differ.recorded(path, right)
+		oldText, oldOk := oldValue.(string)
+		newText, newOk := newValue.(string)
+		if text, ok := left.(string); !oldOk || !newOk || !ok || text != oldText {
+			return NewModified(d.PostPosition(), oldValue, newValue)
+		}
+		d.OldValue, d.NewValue = oldValue, newValue
+		d.Diff = dmp.New().PatchMake(oldText, newText)
+	}
+	return delta
+}
@@ -0,0 +1,171 @@
+package gojsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+)
+
+type toleranceRule struct {
+	patterns []pathPattern // nil for all paths
+	absolute float64
+	relative float64
+}
+
+// Tolerance compares numbers at the paths matched by the patterns as equal
+// when they differ by at most absolute, or by at most relative times the
+// larger of their magnitudes, e.g. Tolerance(1e-9, 0) makes 0.1+0.2 equal
+// to 0.3. With no patterns, the tolerance applies to all numbers. Array items
+// within the tolerance are matched, so that moved items are still detected.
+// When several rules match the same path, the one added last wins.
+func Tolerance(absolute, relative float64, patterns ...string) Option {
+	rule := toleranceRule{absolute: absolute, relative: relative}
+	for _, pattern := range patterns {
+		rule.patterns = append(rule.patterns, mustParsePathPattern(pattern))
+	}
+	return func(differ *Differ) {
+		differ.tolerances = append(differ.tolerances, rule)
+	}
+}
+
+// UseNumber makes Compare decode numbers as json.Number instead of float64,
+// so that integers and decimals are compared exactly (e.g. large integer
+// identifiers above 2^53 do not collide) and deltas hold the numbers
+// verbatim as they were written in the documents.
+func UseNumber() Option {
+	return func(differ *Differ) {
+		differ.useNumber = true
+	}
+}
+
+// tolerance returns the tolerance rule for the number at the path or nil.
+func (differ *Differ) tolerance(path Path) *toleranceRule {
+	for i := len(differ.tolerances) - 1; i >= 0; i-- {
+		rule := &differ.tolerances[i]
+		if rule.patterns == nil {
+			return rule
+		}
+		for _, pattern := range rule.patterns {
+			if pattern.match(path) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+func (rule *toleranceRule) within(left, right float64) bool {
+	difference := math.Abs(left - right)
+	if difference <= rule.absolute {
+		return true
+	}
+	return difference <= rule.relative*math.Max(math.Abs(left), math.Abs(right))
+}
+
+// unmarshal decodes a JSON document, with json.Number values when the
+// UseNumber option is set.
+func (differ *Differ) unmarshal(data []byte, value interface{}) error {
+	if !differ.useNumber {
+		return json.Unmarshal(data, value)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after top-level value")
+	}
+	return nil
+}
+
+// equalNumbers compares two numbers of the same type, json.Number values are
+// compared exactly by their values rather than their representations.
+func (c *comparison) equalNumbers(path Path, left, right interface{}) bool {
+	switch l := left.(type) {
+	case float64:
+		if l == right.(float64) {
+			return true
+		}
+	case json.Number:
+		if equal, ok := equalJsonNumbers(l, right.(json.Number)); ok && equal {
+			return true
+		}
+	}
+
+	if rule := c.tolerance(path); rule != nil {
+		l, lok := toFloat64(left)
+		r, rok := toFloat64(right)
+		return lok && rok && rule.within(l, r)
+	}
+	return false
+}
+
+// equalJsonNumbers compares the values of two json.Number, ok is false when
+// one of them is not a number. The numbers are compared by their significant
+// digits and exponents, as building a big.Rat from a number with a huge
+// exponent, e.g. 1e100000000, takes too long.
+func equalJsonNumbers(left, right json.Number) (equal bool, ok bool) {
+	if left == right {
+		return true, true
+	}
+	l, lok := parseDecimal(left.String())
+	r, rok := parseDecimal(right.String())
+	if !lok || !rok {
+		return false, false
+	}
+	return l.equal(r), true
+}
+
+// A decimal is a number written as 0.digits x 10^exponent, whose digits have
+// neither leading nor trailing zeros. Zero has no digits.
+type decimal struct {
+	negative bool
+	digits   string
+	exponent *big.Int
+}
+
+// parseDecimal parses a number into a decimal.
+func parseDecimal(number string) (decimal, bool) {
+	parts := jsonNumberPattern.FindStringSubmatch(number)
+	if parts == nil {
+		return decimal{}, false
+	}
+	exponent := new(big.Int)
+	if parts[4] != "" {
+		if _, ok := exponent.SetString(parts[4], 10); !ok {
+			return decimal{}, false
+		}
+	}
+	digits := parts[2] + parts[3]
+	significant := strings.TrimLeft(digits, "0")
+	if significant == "" {
+		return decimal{}, true
+	}
+	// the point moves after the integer digits and before the first significant one
+	exponent.Add(exponent, big.NewInt(int64(len(parts[2])-(len(digits)-len(significant)))))
+	return decimal{negative: parts[1] == "-", digits: strings.TrimRight(significant, "0"), exponent: exponent}, true
+}
+
+func (d decimal) equal(another decimal) bool {
+	if d.digits == "" || another.digits == "" {
+		return d.digits == another.digits
+	}
+	return d.negative == another.negative && d.digits == another.digits && d.exponent.Cmp(another.exponent) == 0
+}
+
+// toFloat64 converts float64 and json.Number values to float64.
+func toFloat64(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case float64:
+		return typed, true
+	case json.Number:
+		f, err := typed.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
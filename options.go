@@ -0,0 +1,113 @@
+package gojsondiff
+
+import (
+	"encoding/json"
+)
+
+// An Option configures a Differ created by New.
+type Option func(differ *Differ)
+
+// An ArrayHasher returns the identity of an array item. Items with the same
+// hash are considered to be the same item, even when their contents differ.
+// ok is false when the item has no identity, e.g. it lacks the key fields.
+type ArrayHasher func(item interface{}) (hash string, ok bool)
+
+type arrayHasherRule struct {
+	pattern pathPattern
+	hasher  ArrayHasher
+}
+
+// ArrayKeys matches the items of arrays at the paths matched by pattern using
+// the values of the given object keys, e.g. ArrayKeys("/countries", "name").
+// More than one key can be given to build a composite identity.
+// The pattern is a JSON Pointer where "*" matches any single token.
+// Matched items produce nested deltas at their new index and reordered items
+// produce Moved deltas, like the "objectHash" option of jsondiffpatch.
+func ArrayKeys(pattern string, keys ...string) Option {
+	return ArrayHash(pattern, keysHasher(keys))
+}
+
+// ArrayHash is like ArrayKeys, but the identity of items is computed
+// by the given hasher.
+func ArrayHash(pattern string, hasher ArrayHasher) Option {
+	rule := arrayHasherRule{pattern: mustParsePathPattern(pattern), hasher: hasher}
+	return func(differ *Differ) {
+		differ.arrayHashers = append(differ.arrayHashers, rule)
+	}
+}
+
+// arrayHasher returns the hasher for the array at the path or nil.
+// When several rules match the same path, the one added last wins.
+func (differ *Differ) arrayHasher(path Path) ArrayHasher {
+	for i := len(differ.arrayHashers) - 1; i >= 0; i-- {
+		if differ.arrayHashers[i].pattern.match(path) {
+			return differ.arrayHashers[i].hasher
+		}
+	}
+	return nil
+}
+
+func keysHasher(keys []string) ArrayHasher {
+	return func(item interface{}) (hash string, ok bool) {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			value, found := object[key]
+			if !found {
+				return "", false
+			}
+			values[i] = value
+		}
+		hashBytes, err := json.Marshal(values)
+		if err != nil {
+			return "", false
+		}
+		return string(hashBytes), true
+	}
+}
+
+// UnorderedArrays compares the arrays at the paths matched by the patterns as
+// multisets: equal items produce no delta regardless of their positions and
+// only items without an equal counterpart produce Added or Deleted deltas.
+// Items matched by ArrayKeys produce nested deltas for their changes.
+// With no patterns, all arrays are compared as multisets.
+//
+// Applying the resulting Diff keeps the order of the left side items,
+// added items are appended to the end of the array.
+func UnorderedArrays(patterns ...string) Option {
+	parsed := make([]pathPattern, len(patterns))
+	for i, pattern := range patterns {
+		parsed[i] = mustParsePathPattern(pattern)
+	}
+	return func(differ *Differ) {
+		if len(parsed) == 0 {
+			differ.allArraysUnordered = true
+		}
+		differ.unorderedArrays = append(differ.unorderedArrays, parsed...)
+	}
+}
+
+// isUnordered returns true if the array at the path is compared as a multiset.
+func (differ *Differ) isUnordered(path Path) bool {
+	if differ.allArraysUnordered {
+		return true
+	}
+	for _, pattern := range differ.unorderedArrays {
+		if pattern.match(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// LcsAlgorithm sets the function creating the Lcs which matches the items of
+// two arrays, e.g. LcsAlgorithm(NewLinearLCS) for large arrays. NewLCS is used
+// by default and when newLcs is nil.
+func LcsAlgorithm(newLcs func(left, right []interface{}) Lcs) Option {
+	return func(differ *Differ) {
+		differ.newLcs = newLcs
+	}
+}
@@ -0,0 +1,416 @@
+package gojsondiff
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// A Conflict describes a Delta which does not match the document it is
+// applied to, e.g. a Modified whose old value differs from the actual value.
+type Conflict struct {
+	// Path is the JSON Pointer of the value in the document, using pre indexes
+	// for deleted and moved array items and post indexes otherwise.
+	Path string
+	// Delta is the conflicting Delta.
+	Delta Delta
+	// Reason describes the conflict.
+	Reason string
+	// Expected is the value the Delta expects and Actual the value found,
+	// nil when there is none.
+	Expected interface{}
+	Actual   interface{}
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%T at `%s`: %s", c.Delta, c.Path, c.Reason)
+}
+
+// A PatchError is returned by Patch when deltas conflict with the document.
+type PatchError struct {
+	Conflicts []Conflict
+}
+
+func (e *PatchError) Error() string {
+	messages := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		messages[i] = conflict.String()
+	}
+	return fmt.Sprintf("%d conflict(s) patching the document: %s", len(e.Conflicts), strings.Join(messages, "; "))
+}
+
+// Patch applies a Diff to a copy of a JSON document of any type and returns
+// the patched copy, leaving the document and the Diff untouched.
+// Values are compared with the options of the Differ to the old values
+// held by the deltas; deltas which do not match the document are skipped
+// and reported as Conflicts by a *PatchError, along with the document
+// patched by the other deltas. Like ApplyPatch, the objects and arrays of the
+// copy are normalized by the normalizers of the Differ first.
+func (differ *Differ) Patch(json interface{}, patch Diff) (interface{}, error) {
+	p := &patcher{comparison: differ.newComparison(context.Background(), false)}
+	p.normalizedShapes = true
+	result := p.applyDeltas(Path{}, deepCopy(differ.normalizeShape(Path{}, json)), patch.Deltas())
+	if len(p.conflicts) > 0 {
+		return result, &PatchError{Conflicts: p.conflicts}
+	}
+	return result, nil
+}
+
+// A patcher holds the state of a Patch.
+type patcher struct {
+	*comparison
+	conflicts []Conflict
+}
+
+// conflict records a conflict of the delta at the path.
+func (p *patcher) conflict(path Path, delta Delta, reason string, expected interface{}, actual interface{}) {
+	p.conflicts = append(p.conflicts, Conflict{
+		Path:     path.String(),
+		Delta:    delta,
+		Reason:   reason,
+		Expected: expected,
+		Actual:   actual,
+	})
+}
+
+// equal returns true if the value expected by a delta and the actual value of
+// the document, whose objects and arrays are normalized, are the same for the
+// Differ.
+func (p *patcher) equal(path Path, expected interface{}, actual interface{}) bool {
+	if !p.recordNormalized {
+		expected = p.normalizeShape(path, expected)
+	}
+	same, _ := p.compareValues(path, expected, actual)
+	return same
+}
+
+// applyDeltas applies the deltas of the value at the path and returns the
+// patched value.
+func (p *patcher) applyDeltas(path Path, object interface{}, deltas []Delta) interface{} {
+	if len(deltas) == 1 {
+		if delta, ok := deltas[0].(PostDelta); ok && isRoot(delta.PostPosition()) {
+			return p.applyRoot(path, object, deltas[0])
+		}
+		if delta, ok := deltas[0].(PreDelta); ok && isRoot(delta.PrePosition()) {
+			return p.applyRoot(path, object, deltas[0])
+		}
+	}
+
+	switch typed := object.(type) {
+	case map[string]interface{}:
+		// renamed members are taken out first, so that their old names can
+		// be reused by the other deltas
+		renamed := make(map[*Moved]interface{})
+		for _, delta := range deltas {
+			if moved, ok := delta.(*Moved); ok {
+				if value, ok := p.takeRenamed(path, typed, moved); ok {
+					renamed[moved] = value
+				}
+			}
+		}
+		for _, delta := range deltas {
+			if moved, ok := delta.(*Moved); ok {
+				if value, ok := renamed[moved]; ok {
+					p.putRenamed(path, typed, moved, value)
+				}
+				continue
+			}
+			p.applyToObject(path, typed, delta)
+		}
+		return typed
+	case []interface{}:
+		return p.applyToArray(path, typed, deltas)
+	}
+	if len(deltas) > 0 {
+		p.conflict(path, deltas[0], "not an object or an array", nil, object)
+	}
+	return object
+}
+
+// applyRoot applies a delta of the whole value at the path.
+func (p *patcher) applyRoot(path Path, object interface{}, delta Delta) interface{} {
+	switch d := delta.(type) {
+	case *Added:
+		if object != nil {
+			p.conflict(path, delta, "value already exists", nil, object)
+			return object
+		}
+		return deepCopy(d.Value)
+	case *Deleted:
+		if !p.equal(path, d.Value, object) {
+			p.conflict(path, delta, "value differs", d.Value, object)
+			return object
+		}
+		return nil
+	}
+	patched, _ := p.modify(path, object, delta)
+	return patched
+}
+
+// modify returns the value modified by a Modified, a TextDiff or a TypeChanged,
+// or the value itself and false on conflicts.
+func (p *patcher) modify(path Path, value interface{}, delta Delta) (interface{}, bool) {
+	switch d := delta.(type) {
+	case *TextDiff:
+		text, ok := value.(string)
+		if !ok {
+			p.conflict(path, delta, "not a string", d.OldValue, value)
+			return value, false
+		}
+		// old values are unknown once unmarshalled
+		if d.OldValue != nil && d.OldValue != text {
+			p.conflict(path, delta, "value differs", d.OldValue, value)
+			return value, false
+		}
+		patched, successes := dmp.New().PatchApply(d.Diff, text)
+		for _, success := range successes {
+			if !success {
+				p.conflict(path, delta, "text patch does not apply", d.OldValue, value)
+				return value, false
+			}
+		}
+		return patched, true
+	case *Modified:
+		if !p.equal(path, d.OldValue, value) {
+			p.conflict(path, delta, "value differs", d.OldValue, value)
+			return value, false
+		}
+		return deepCopy(d.NewValue), true
+	case *TypeChanged:
+		if !p.equal(path, d.OldValue, value) {
+			p.conflict(path, delta, "value differs", d.OldValue, value)
+			return value, false
+		}
+		return d.NewValue, true
+	}
+	p.conflict(path, delta, "unknown delta type", nil, value)
+	return value, false
+}
+
+// applyToObject applies a delta of a member of the object.
+func (p *patcher) applyToObject(path Path, object map[string]interface{}, delta Delta) {
+	var name Name
+	var ok bool
+	switch d := delta.(type) {
+	case PostDelta:
+		name, ok = d.PostPosition().(Name)
+	case PreDelta:
+		name, ok = d.PrePosition().(Name)
+	}
+	if !ok {
+		p.conflict(path, delta, "not an object member delta", nil, object)
+		return
+	}
+	memberPath := path.child(name)
+	value, exists := object[string(name)]
+
+	switch d := delta.(type) {
+	case *Added:
+		if exists {
+			p.conflict(memberPath, delta, "value already exists", nil, value)
+			return
+		}
+		object[string(name)] = deepCopy(d.Value)
+	case *Deleted:
+		if !exists {
+			p.conflict(memberPath, delta, "value does not exist", d.Value, nil)
+			return
+		}
+		if !p.equal(memberPath, d.Value, value) {
+			p.conflict(memberPath, delta, "value differs", d.Value, value)
+			return
+		}
+		delete(object, string(name))
+	default:
+		if !exists {
+			p.conflict(memberPath, delta, "value does not exist", nil, nil)
+			return
+		}
+		if patched, ok := p.applyToValue(memberPath, value, delta); ok {
+			object[string(name)] = patched
+		}
+	}
+}
+
+// takeRenamed removes the member renamed by the Moved delta from the object
+// and returns its value, or false on conflicts.
+func (p *patcher) takeRenamed(path Path, object map[string]interface{}, moved *Moved) (interface{}, bool) {
+	name, ok := moved.PrePosition().(Name)
+	if _, named := moved.PostPosition().(Name); !ok || !named {
+		p.conflict(path, moved, "not an object member delta", nil, object)
+		return nil, false
+	}
+	memberPath := path.child(name)
+	value, exists := object[string(name)]
+	if !exists {
+		p.conflict(memberPath, moved, "value does not exist", moved.Value, nil)
+		return nil, false
+	}
+	// values of renamed members are unknown once unmarshalled
+	if moved.Value != nil && !p.equal(memberPath, moved.Value, value) {
+		p.conflict(memberPath, moved, "value differs", moved.Value, value)
+		return nil, false
+	}
+	delete(object, string(name))
+	return value, true
+}
+
+// putRenamed puts the value of a member taken out by takeRenamed at its new
+// name and applies the changes of the value, if any. The member keeps its old
+// name when the new one is taken.
+func (p *patcher) putRenamed(path Path, object map[string]interface{}, moved *Moved, value interface{}) {
+	name := moved.PostPosition().(Name)
+	memberPath := path.child(name)
+	if existing, exists := object[string(name)]; exists {
+		p.conflict(memberPath, moved, "value already exists", nil, existing)
+		object[string(moved.PrePosition().(Name))] = value
+		return
+	}
+	object[string(name)] = value
+	if nested, ok := moved.Delta.(Delta); ok {
+		if patched, ok := p.applyToValue(memberPath, value, nested); ok {
+			object[string(name)] = patched
+		}
+	}
+}
+
+// applyToValue applies a delta which changes an existing value.
+func (p *patcher) applyToValue(path Path, value interface{}, delta Delta) (interface{}, bool) {
+	switch d := delta.(type) {
+	case *Object:
+		if _, ok := value.(map[string]interface{}); !ok {
+			p.conflict(path, delta, "not an object", nil, value)
+			return value, false
+		}
+		return p.applyDeltas(path, value, d.Deltas), true
+	case *Array:
+		if _, ok := value.([]interface{}); !ok {
+			p.conflict(path, delta, "not an array", nil, value)
+			return value, false
+		}
+		return p.applyDeltas(path, value, d.Deltas), true
+	}
+	return p.modify(path, value, delta)
+}
+
+// applyToArray applies the deltas of an array like applyDeltas does, removing
+// items in descending order of their pre indexes, then inserting and
+// changing items in ascending order of their post indexes.
+func (p *patcher) applyToArray(path Path, array []interface{}, deltas []Delta) []interface{} {
+	pres := make(preDeltas, 0)
+	posts := make(postDeltas, 0)
+	for _, delta := range deltas {
+		if pre, ok := delta.(PreDelta); ok {
+			if _, ok := pre.PrePosition().(Index); !ok {
+				p.conflict(path, delta, "not an array item delta", nil, array)
+				continue
+			}
+			pres = append(pres, pre)
+		}
+		if post, ok := delta.(PostDelta); ok {
+			if _, ok := post.PostPosition().(Index); !ok {
+				p.conflict(path, delta, "not an array item delta", nil, array)
+				continue
+			}
+			posts = append(posts, post)
+		}
+	}
+	sort.Sort(pres)
+	sort.Stable(posts)
+
+	moved := make(map[*Moved]interface{})
+	skipped := make(map[*Moved]bool)
+	for _, delta := range pres {
+		i := int(delta.PrePosition().(Index))
+		itemPath := path.child(Index(i))
+		if i >= len(array) {
+			p.conflict(itemPath, delta.(Delta), "index out of range", nil, nil)
+			if d, ok := delta.(*Moved); ok {
+				skipped[d] = true
+			}
+			continue
+		}
+		switch d := delta.(type) {
+		case *Deleted:
+			if !p.equal(itemPath, d.Value, array[i]) {
+				p.conflict(itemPath, delta.(Delta), "value differs", d.Value, array[i])
+				continue
+			}
+		case *Moved:
+			// values of moved items are unknown once unmarshalled
+			if d.Value != nil && !p.equal(itemPath, d.Value, array[i]) {
+				p.conflict(itemPath, delta.(Delta), "value differs", d.Value, array[i])
+				skipped[d] = true
+				continue
+			}
+			moved[d] = array[i]
+		}
+		array = append(array[:i], array[i+1:]...)
+	}
+
+	for _, delta := range posts {
+		i := int(delta.PostPosition().(Index))
+		itemPath := path.child(Index(i))
+		switch d := delta.(type) {
+		case *Added:
+			if i > len(array) {
+				p.conflict(itemPath, delta.(Delta), "index out of range", nil, nil)
+				continue
+			}
+			array = insertItem(array, i, deepCopy(d.Value))
+		case *Moved:
+			if skipped[d] {
+				continue
+			}
+			if i > len(array) {
+				p.conflict(itemPath, delta.(Delta), "index out of range", nil, nil)
+				continue
+			}
+			array = insertItem(array, i, moved[d])
+			if nested, ok := d.Delta.(Delta); ok {
+				if patched, ok := p.applyToValue(itemPath, array[i], nested); ok {
+					array[i] = patched
+				}
+			}
+		default:
+			if i >= len(array) {
+				p.conflict(itemPath, delta.(Delta), "index out of range", nil, nil)
+				continue
+			}
+			if patched, ok := p.applyToValue(itemPath, array[i], delta.(Delta)); ok {
+				array[i] = patched
+			}
+		}
+	}
+	return array
+}
+
+// insertItem inserts the item at the index of the array.
+func insertItem(array []interface{}, index int, item interface{}) []interface{} {
+	array = append(array, nil)
+	copy(array[index+1:], array[index:])
+	array[index] = item
+	return array
+}
+
+// deepCopy returns a copy of a JSON value, objects and arrays included.
+func deepCopy(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(typed))
+		for name, child := range typed {
+			copied[name] = deepCopy(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for i, child := range typed {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,162 @@
+package gojsondiff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A Path represents the location of a value in a JSON document as the list
+// of Positions from the root to the value.
+type Path []Position
+
+// String returns the Path as a JSON Pointer (RFC 6901).
+func (p Path) String() (pointer string) {
+	var builder strings.Builder
+	for _, position := range p {
+		builder.WriteRune('/')
+		builder.WriteString(escapePointerToken(position.String()))
+	}
+	return builder.String()
+}
+
+// child returns a new Path with the position appended.
+// The receiver is never modified, so sibling Paths do not share storage.
+func (p Path) child(position Position) Path {
+	child := make(Path, len(p)+1)
+	copy(child, p)
+	child[len(p)] = position
+	return child
+}
+
+// position returns the Position of the value at the path in its parent,
+// which is Root for the empty path.
+func (p Path) position() Position {
+	if len(p) == 0 {
+		return Root{}
+	}
+	return p[len(p)-1]
+}
+
+// escapePointerToken escapes a reference token as described in RFC 6901.
+func escapePointerToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	return strings.ReplaceAll(token, "/", "~1")
+}
+
+// unescapePointerToken reverses escapePointerToken. Every '~' of the token
+// must be followed by '0' or '1'.
+func unescapePointerToken(token string) (string, error) {
+	if !strings.Contains(token, "~") {
+		return token, nil
+	}
+	var builder strings.Builder
+	for i := 0; i < len(token); i++ {
+		if token[i] != '~' {
+			builder.WriteByte(token[i])
+			continue
+		}
+		if i+1 == len(token) || (token[i+1] != '0' && token[i+1] != '1') {
+			return "", errors.New("'~' must be followed by '0' or '1'")
+		}
+		if token[i+1] == '0' {
+			builder.WriteByte('~')
+		} else {
+			builder.WriteByte('/')
+		}
+		i++
+	}
+	return builder.String(), nil
+}
+
+// splitPointer splits a JSON Pointer into its unescaped reference tokens.
+func splitPointer(pointer string) (tokens []string, err error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON Pointer `%s`: must be empty or start with '/'", pointer)
+	}
+	tokens = strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		if tokens[i], err = unescapePointerToken(token); err != nil {
+			return nil, fmt.Errorf("invalid JSON Pointer `%s`: %s", pointer, err)
+		}
+	}
+	return tokens, nil
+}
+
+const (
+	// PatternWildcard matches any single reference token in a path pattern.
+	PatternWildcard = "*"
+	// PatternAnyDepth matches any number of reference tokens, including none,
+	// in a path pattern.
+	PatternAnyDepth = "**"
+)
+
+// A pathPattern is a JSON Pointer whose reference tokens may be wildcards.
+type pathPattern []string
+
+// parsePathPattern parses a JSON Pointer with optional wildcard tokens,
+// e.g. "/items/*/tags" or "/**/updatedAt".
+func parsePathPattern(pattern string) (pathPattern, error) {
+	tokens, err := splitPointer(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return pathPattern(tokens), nil
+}
+
+// ValidatePattern returns the reason why a path pattern is invalid, if any,
+// e.g. to check the patterns given to the options, which panic on them.
+func ValidatePattern(pattern string) error {
+	_, err := parsePathPattern(pattern)
+	return err
+}
+
+// mustParsePathPattern is like parsePathPattern but panics on invalid patterns.
+// It is used by Options, which have no way to report errors.
+func mustParsePathPattern(pattern string) pathPattern {
+	parsed, err := parsePathPattern(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// match returns true if the whole path is matched by the pattern.
+func (pattern pathPattern) match(path Path) bool {
+	return matchTokens(pattern, path, false)
+}
+
+// matchBelow returns true if the pattern may match a descendant of the path.
+func (pattern pathPattern) matchBelow(path Path) bool {
+	return matchTokens(pattern, path, true)
+}
+
+// matchTokens matches the pattern against the path. When below is true,
+// the pattern only needs to match a prefix of one of the descendants of path.
+func matchTokens(pattern []string, path Path, below bool) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0 && !below
+	}
+	if len(path) == 0 {
+		if below {
+			return true
+		}
+		for _, token := range pattern {
+			if token != PatternAnyDepth {
+				return false
+			}
+		}
+		return true
+	}
+	switch pattern[0] {
+	case PatternAnyDepth:
+		return matchTokens(pattern[1:], path, below) || matchTokens(pattern, path[1:], below)
+	case PatternWildcard:
+		return matchTokens(pattern[1:], path[1:], below)
+	default:
+		return pattern[0] == path[0].String() && matchTokens(pattern[1:], path[1:], below)
+	}
+}
@@ -0,0 +1,67 @@
+package gojsondiff
+
+import (
+	"testing"
+)
+
+func TestPathString(t *testing.T) {
+	cases := []struct {
+		path    Path
+		pointer string
+	}{
+		{path: Path{}, pointer: ""},
+		{path: Path{Name("")}, pointer: "/"},
+		{path: Path{Name("items"), Index(2), Name("name")}, pointer: "/items/2/name"},
+		{path: Path{Name("a/b"), Name("m~n")}, pointer: "/a~1b/m~0n"},
+	}
+
+	for i, c := range cases {
+		if actual := c.path.String(); actual != c.pointer {
+			t.Errorf("test case %d failed, actual: %s, expected: %s", i, actual, c.pointer)
+		}
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    Path
+		match   bool
+		below   bool
+	}{
+		{pattern: "", path: Path{}, match: true, below: false},
+		{pattern: "/items", path: Path{Name("items")}, match: true, below: false},
+		{pattern: "/items", path: Path{Name("other")}, match: false, below: false},
+		{pattern: "/items/*/updatedAt", path: Path{Name("items"), Index(3), Name("updatedAt")}, match: true, below: false},
+		{pattern: "/items/*/updatedAt", path: Path{Name("items"), Index(3)}, match: false, below: true},
+		{pattern: "/items/*/updatedAt", path: Path{Name("items")}, match: false, below: true},
+		{pattern: "/items/*/updatedAt", path: Path{Name("other")}, match: false, below: false},
+		{pattern: "/a~1b/m~0n", path: Path{Name("a/b"), Name("m~n")}, match: true, below: false},
+		{pattern: "/~01", path: Path{Name("~1")}, match: true, below: false},
+		{pattern: "/**", path: Path{}, match: true, below: true},
+		{pattern: "/**/requestId", path: Path{Name("requestId")}, match: true, below: true},
+		{pattern: "/**/requestId", path: Path{Name("a"), Index(0), Name("requestId")}, match: true, below: true},
+		{pattern: "/**/requestId", path: Path{Name("a"), Index(0)}, match: false, below: true},
+		{pattern: "/metadata/**", path: Path{Name("metadata")}, match: true, below: true},
+		{pattern: "/metadata/**", path: Path{Name("spec")}, match: false, below: false},
+	}
+
+	for i, c := range cases {
+		pattern, err := parsePathPattern(c.pattern)
+		if err != nil {
+			t.Fatalf("test case %d failed to parse: %s", i, err)
+		}
+		if actual := pattern.match(c.path); actual != c.match {
+			t.Errorf("test case %d failed at match, actual: %t, expected: %t", i, actual, c.match)
+		}
+		if actual := pattern.matchBelow(c.path); actual != c.below {
+			t.Errorf("test case %d failed at below, actual: %t, expected: %t", i, actual, c.below)
+		}
+	}
+
+	for _, invalid := range []string{"items", "/a~2", "/a~", "/~/b"} {
+		if _, err := parsePathPattern(invalid); err == nil {
+			t.Errorf("expected an error for the pattern %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package gojsondiff
+
+import "fmt"
+
+// DetectRenames makes a Differ report renamed object members: a member
+// deleted from an object and a member added to it whose values have at least
+// the given similarity produce a Moved delta from the old name to the new one
+// instead of a Deleted and an Added delta. Values which are not equal are
+// compared, and the Moved delta holds the changes of the value.
+// A similarity of 1 only pairs equal values and a similarity of 0 or less
+// disables the detection; similarities are the ones used to match array
+// items, e.g. 0.8 for objects sharing most of their members. Each deleted member is paired with at most one added member, the most
+// similar pairs first. Null values are not paired, as the values of Moved
+// deltas are unknown once unmarshalled.
+func DetectRenames(similarity float64) Option {
+	if similarity > 1 {
+		similarity = 1
+	}
+	return func(differ *Differ) {
+		differ.renameSimilarity = similarity
+	}
+}
+
+// detectRenames replaces the Deleted and Added deltas of the members of the
+// object at the path which are renamed by Moved deltas.
+func (c *comparison) detectRenames(path Path, deltas []Delta) []Delta {
+	var deleted []*Deleted
+	var added []*Added
+	for _, delta := range deltas {
+		switch d := delta.(type) {
+		case *Deleted:
+			deleted = append(deleted, d)
+		case *Added:
+			added = append(added, d)
+		}
+	}
+	if len(deleted) == 0 || len(added) == 0 {
+		return deltas
+	}
+	// too many pairs to compare, the members are reported as deleted and added
+	if c.exceeded(BudgetSimilarityCells, path, c.maxSimilarityCells, len(deleted)*len(added)) {
+		return deltas
+	}
+
+	pairs := c.pairSimilarValues(len(deleted), len(added), c.renameSimilarity, func(d, a int) (Path, interface{}, interface{}, bool) {
+		if deleted[d].Value == nil || added[a].Value == nil {
+			return nil, nil, nil, false
+		}
+		return path.child(added[a].PostPosition()), deleted[d].Value, added[a].Value, true
+	})
+
+	renamed := make(map[Delta]*Moved)
+	paired := make(map[Delta]bool)
+	for _, pair := range pairs {
+		d, a := deleted[pair.deleted], added[pair.added]
+		paired[d], paired[a] = true, true
+		renamed[d] = NewMoved(d.PrePosition(), a.PostPosition(), d.Value, pair.delta)
+	}
+
+	result := make([]Delta, 0, len(deltas)-len(renamed))
+	for _, delta := range deltas {
+		switch {
+		case renamed[delta] != nil:
+			result = append(result, renamed[delta])
+		case !paired[delta]:
+			result = append(result, delta)
+		}
+	}
+	return result
+}
+
+// isRename returns true if the delta is a Moved delta renaming a member of an
+// object.
+func isRename(delta Delta) bool {
+	moved, ok := delta.(*Moved)
+	if !ok {
+		return false
+	}
+	_, named := moved.PrePosition().(Name)
+	return named
+}
+
+// expandRenames replaces the Moved deltas renaming members of an object by
+// the Deleted delta of the old member and the Added delta of the new one,
+// which need the value of the member.
+func expandRenames(deltas []Delta) ([]Delta, error) {
+	expanded := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		if !isRename(delta) {
+			expanded = append(expanded, delta)
+			continue
+		}
+		moved := delta.(*Moved)
+		// values of moved members are unknown once unmarshalled
+		if moved.Value == nil {
+			return nil, fmt.Errorf("member renamed from `%s` to `%s` without its value", moved.PrePosition(), moved.PostPosition())
+		}
+		nested, _ := moved.Delta.(Delta)
+		value, err := patchValue(nested, moved.Value)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, NewDeleted(moved.PrePosition(), moved.Value), NewAdded(moved.PostPosition(), value))
+	}
+	return expanded, nil
+}
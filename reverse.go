@@ -0,0 +1,100 @@
+package gojsondiff
+
+import (
+	"strings"
+
+	dmp "github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// Reverse returns a Diff which turns the right side of the Diff back into its
+// left side, like the reverse function of jsondiffpatch.
+func (d *diff) Reverse() Diff {
+	reversed := &diff{deltas: reverseDeltas(d.deltas), ignored: d.ignored}
+	if d.strict != nil {
+		reversed.strict = d.strict.Reverse().(*diff)
+	}
+	return reversed
+}
+
+// Unpatch reverts a Diff applied to a JSON document and returns the document,
+// so that Unpatch(ApplyPatch(a, d), d) equals a, with its objects and arrays
+// normalized like ApplyPatch does. It is as destructive as ApplyPatch.
+func (differ *Differ) Unpatch(json interface{}, patch Diff) interface{} {
+	return differ.ApplyPatch(json, patch.Reverse())
+}
+
+// reverseDeltas reverses the deltas of an object, an array or a whole document.
+func reverseDeltas(deltas []Delta) []Delta {
+	position := func(position Position) Position { return position }
+	if isArrayDeltas(deltas) {
+		// kept items are at the pre indexes of the reversed post positions
+		indexes := newArrayIndexMap(deltas)
+		position = func(position Position) Position {
+			return Index(indexes.preIndex(int(position.(Index))))
+		}
+	}
+
+	reversed := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		reversed = append(reversed, reverseDelta(delta, position))
+	}
+	return reversed
+}
+
+// reverseDelta reverses a delta, position maps the post position of the
+// changes of kept items to their position in the reversed delta.
+func reverseDelta(delta Delta, position func(Position) Position) Delta {
+	switch d := delta.(type) {
+	case *Object:
+		return NewObject(position(d.PostPosition()), reverseDeltas(d.Deltas))
+	case *Array:
+		return NewArray(position(d.PostPosition()), reverseDeltas(d.Deltas))
+	case *Added:
+		return NewDeleted(d.PostPosition(), d.Value)
+	case *Deleted:
+		return NewAdded(d.PrePosition(), d.Value)
+	case *Modified:
+		return NewModified(position(d.PostPosition()), d.NewValue, d.OldValue)
+	case *TextDiff:
+		return NewTextDiff(position(d.PostPosition()), reversePatches(d.Diff), d.NewValue, d.OldValue)
+	case *TypeChanged:
+		return NewTypeChanged(position(d.PostPosition()), d.NewValue, d.OldValue)
+	case *Moved:
+		var movedDelta Delta
+		value := d.Value
+		if nested, ok := d.Delta.(Delta); ok {
+			// the changes of the moved item are at its reversed destination
+			movedDelta = reverseDelta(nested, func(Position) Position { return d.PrePosition() })
+			// the reversed move takes the changed value
+			if value != nil {
+				value, _ = patchValue(nested, value)
+			}
+		}
+		return NewMoved(d.PostPosition(), d.PrePosition(), value, movedDelta)
+	}
+	return delta
+}
+
+// reversePatches inverts text patches by swapping their deletions and
+// insertions, as well as the ranges of their headers.
+func reversePatches(patches []dmp.Patch) []dmp.Patch {
+	patcher := dmp.New()
+	lines := strings.Split(patcher.PatchToText(patches), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@ -"):
+			// "@@ -start1,length1 +start2,length2 @@"
+			fields := strings.Fields(line)
+			if len(fields) == 4 {
+				lines[i] = "@@ -" + fields[2][1:] + " +" + fields[1][1:] + " @@"
+			}
+		case strings.HasPrefix(line, "-"):
+			lines[i] = "+" + line[1:]
+		case strings.HasPrefix(line, "+"):
+			lines[i] = "-" + line[1:]
+		}
+	}
+	// the text is made by PatchToText, so that it is always valid
+	reversed, _ := patcher.PatchFromText(strings.Join(lines, "\n"))
+	return reversed
+}
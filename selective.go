@@ -0,0 +1,106 @@
+package gojsondiff
+
+// A ChangeKind is the kind of the delta of a Change.
+type ChangeKind int
+
+const (
+	// ChangeAdded is a value added by an Added delta.
+	ChangeAdded ChangeKind = iota
+	// ChangeDeleted is a value deleted by a Deleted delta.
+	ChangeDeleted
+	// ChangeModified is a value replaced by a Modified delta.
+	ChangeModified
+	// ChangeText is a string changed by a TextDiff delta.
+	ChangeText
+	// ChangeType is a value replaced by an equivalent value of another type
+	// by a TypeChanged delta.
+	ChangeType
+	// ChangeMoved is an array item or an object member moved by a Moved
+	// delta. The changes of the moved value are separate changes.
+	ChangeMoved
+	// ChangeContainer is an object or an array holding changes, i.e. an
+	// Object or an Array delta.
+	ChangeContainer
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeDeleted:
+		return "deleted"
+	case ChangeModified:
+		return "modified"
+	case ChangeText:
+		return "text"
+	case ChangeType:
+		return "type"
+	case ChangeMoved:
+		return "moved"
+	}
+	return "container"
+}
+
+// Kind returns the kind of the delta of the change.
+func (change Change) Kind() ChangeKind {
+	switch change.Delta.(type) {
+	case *Added:
+		return ChangeAdded
+	case *Deleted:
+		return ChangeDeleted
+	case *Modified:
+		return ChangeModified
+	case *TextDiff:
+		return ChangeText
+	case *TypeChanged:
+		return ChangeType
+	case *Moved:
+		return ChangeMoved
+	}
+	return ChangeContainer
+}
+
+// OldValue returns the value in the left document held by the delta of the
+// change, nil for Added deltas, containers and unmarshalled text diffs.
+func (change Change) OldValue() interface{} {
+	switch d := change.Delta.(type) {
+	case *Deleted:
+		return d.Value
+	case *Moved:
+		return d.Value
+	}
+	value, _ := oldValue(change.Delta)
+	return value
+}
+
+// NewValue returns the value in the right document held by the delta of the
+// change, nil for Deleted deltas, containers and unmarshalled text diffs.
+// The new value of a Moved delta is the moved value before its own changes.
+func (change Change) NewValue() interface{} {
+	if d, ok := change.Delta.(*Moved); ok {
+		return d.Value
+	}
+	value, _ := newValue(change.Delta)
+	return value
+}
+
+// A Decision accepts a change to apply by returning true, or rejects it.
+type Decision func(change Change) bool
+
+var (
+	// AcceptAll accepts every change.
+	AcceptAll Decision = func(Change) bool { return true }
+	// RejectAll rejects every change.
+	RejectAll Decision = func(Change) bool { return false }
+)
+
+// ApplySelected applies the changes of the Diff accepted by the decision to
+// a JSON document and returns the patched document along with the Diff of
+// the accepted changes, which Filter computes. The decision is called with
+// the changes listed by Diff.Changes; the indexes of array items are
+// rewritten for the rejected additions, deletions and moves. Like ApplyPatch,
+// this method is destructive.
+func (differ *Differ) ApplySelected(json interface{}, patch Diff, decision Decision) (patched interface{}, applied Diff) {
+	applied = patch.Filter(decision)
+	return differ.ApplyPatch(json, applied), applied
+}
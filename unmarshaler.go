@@ -1,6 +1,7 @@
 package gojsondiff
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,6 +11,9 @@ import (
 )
 
 type Unmarshaller struct {
+	// UseNumber makes the Unmarshaller decode numbers as json.Number,
+	// so that values of the deltas keep the numbers verbatim.
+	UseNumber bool
 }
 
 func NewUnmarshaller() *Unmarshaller {
@@ -17,9 +21,19 @@ func NewUnmarshaller() *Unmarshaller {
 }
 
 func (um *Unmarshaller) UnmarshalBytes(diffBytes []byte) (Diff, error) {
-	var diffObj map[string]interface{}
-	json.Unmarshal(diffBytes, &diffObj)
-	return um.UnmarshalObject(diffObj)
+	var diffValue interface{}
+	var err error
+	if um.UseNumber {
+		decoder := json.NewDecoder(bytes.NewReader(diffBytes))
+		decoder.UseNumber()
+		err = decoder.Decode(&diffValue)
+	} else {
+		err = json.Unmarshal(diffBytes, &diffValue)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return um.UnmarshalValue(diffValue)
 }
 
 func (um *Unmarshaller) UnmarshalString(diffString string) (Diff, error) {
@@ -27,17 +41,46 @@ func (um *Unmarshaller) UnmarshalString(diffString string) (Diff, error) {
 }
 
 func (um *Unmarshaller) UnmarshalReader(diffReader io.Reader) (Diff, error) {
-	var diffBytes []byte
-	io.ReadFull(diffReader, diffBytes)
+	diffBytes, err := io.ReadAll(diffReader)
+	if err != nil {
+		return nil, err
+	}
 	return um.UnmarshalBytes(diffBytes)
 }
 
+// UnmarshalValue unmarshals a delta of any JSON document: an object for
+// objects and arrays, an array for a delta of the whole document (e.g. two
+// documents of different types) or null when there is no difference.
+func (um *Unmarshaller) UnmarshalValue(diffValue interface{}) (Diff, error) {
+	switch typed := diffValue.(type) {
+	case map[string]interface{}:
+		return um.UnmarshalObject(typed)
+	case []interface{}:
+		delta, err := process(Root{}, typed)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return nil, fmt.Errorf("invalid delta of length %d: `%v`", len(typed), typed)
+		}
+		return &diff{deltas: []Delta{delta}}, nil
+	case nil:
+		return &diff{deltas: []Delta{}}, nil
+	}
+	return nil, fmt.Errorf("invalid delta type (%T) for value: `%v`", diffValue, diffValue)
+}
+
 func (um *Unmarshaller) UnmarshalObject(diffObj map[string]interface{}) (Diff, error) {
-	result, err := process(Name(""), diffObj)
+	result, err := process(Root{}, diffObj)
 	if err != nil {
 		return nil, err
 	}
-	return &diff{deltas: result.(*Object).Deltas}, nil
+	switch typed := result.(type) {
+	case *Array:
+		return &diff{deltas: typed.Deltas}, nil
+	default:
+		return &diff{deltas: typed.(*Object).Deltas}, nil
+	}
 }
 
 func process(position Position, object interface{}) (Delta, error) {
@@ -69,28 +112,7 @@ func process(position Position, object interface{}) (Delta, error) {
 				deltas = append(deltas, childDelta)
 			}
 
-			for _, d := range deltas {
-				switch d.(type) {
-				case *Moved:
-					moved := d.(*Moved)
-
-					var dd interface{}
-					var i int
-					for i, dd = range deltas {
-						switch ddType := dd.(type) {
-						case *Moved: // TODO: <== this is a NO-OP in Golang (no fallthrough); why is it here?
-							fmt.Printf("[WARNING] unmarshaller: process(): unhandled case: Type=`%T`", ddType)
-						case PostDelta:
-							if moved.PostPosition() == ddType.PostPosition() {
-								moved.Delta = ddType
-								deltas = append(deltas[:i], deltas[i+1:]...)
-							}
-						}
-					}
-				}
-			}
-
-			delta = NewArray(position, deltas)
+			delta = NewArray(position, attachMoves(deltas))
 		} else {
 			deltas := make([]Delta, 0, len(o))
 			for name, value := range o {
@@ -100,7 +122,7 @@ func process(position Position, object interface{}) (Delta, error) {
 				}
 				deltas = append(deltas, childDelta)
 			}
-			delta = NewObject(position, deltas)
+			delta = NewObject(position, attachMoves(deltas))
 		}
 	case []interface{}:
 		o := object.([]interface{})
@@ -110,18 +132,31 @@ func process(position Position, object interface{}) (Delta, error) {
 		case 2:
 			delta = NewModified(position, o[0], o[1])
 		case 3:
-			switch o[2] {
-			case float64(0):
+			marker, ok := toFloat64(o[2])
+			if !ok {
+				return nil, fmt.Errorf("unknown delta type (%T) for value: `%v`", o[2], o[2])
+			}
+			switch marker {
+			case 0:
 				delta = NewDeleted(position, o[0])
-			case float64(2):
+			case 2:
 				dmp := dmp.New()
 				patches, err := dmp.PatchFromText(o[0].(string))
 				if err != nil {
 					return nil, err
 				}
 				delta = NewTextDiff(position, patches, nil, nil)
-			case float64(3):
-				delta = NewMoved(position, Index(int(o[1].(float64))), nil, nil)
+			case 4:
+				delta = NewTypeChanged(position, o[0], o[1])
+			case 3:
+				// members of objects are renamed to a name, items of arrays
+				// are moved to an index
+				if name, ok := o[1].(string); ok {
+					delta = NewMoved(position, Name(name), nil, nil)
+					break
+				}
+				destination, _ := toFloat64(o[1])
+				delta = NewMoved(position, Index(int(destination)), nil, nil)
 			default:
 				return nil, fmt.Errorf("unknown delta type (%T) for value: `%v`", o[2], o[2])
 			}
@@ -130,3 +165,28 @@ func process(position Position, object interface{}) (Delta, error) {
 
 	return delta, nil
 }
+
+// attachMoves attaches the delta of a moved item or a renamed member, which
+// is at its new index or name, to the Moved delta moving it.
+func attachMoves(deltas []Delta) []Delta {
+	moves := make(map[Position]*Moved)
+	for _, delta := range deltas {
+		if moved, ok := delta.(*Moved); ok {
+			moves[moved.PostPosition()] = moved
+		}
+	}
+	if len(moves) == 0 {
+		return deltas
+	}
+	result := make([]Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		if post, ok := delta.(PostDelta); ok {
+			if moved, ok := moves[post.PostPosition()]; ok && Delta(moved) != delta {
+				moved.Delta = delta
+				continue
+			}
+		}
+		result = append(result, delta)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package gojsondiff
+
+// A Change is a Delta of a Diff along with the paths of the value it changes
+// in the left and in the right documents.
+type Change struct {
+	// Delta is the delta, which is an Object or an Array for the values
+	// whose members or items changed.
+	Delta Delta
+	// PrePath is the path of the value in the left document, nil for Added
+	// deltas.
+	PrePath Path
+	// PostPath is the path of the value in the right document, nil for
+	// Deleted deltas.
+	PostPath Path
+}
+
+// Path returns the path of the value in the right document, or in the left
+// document for Deleted deltas.
+func (change Change) Path() Path {
+	if change.PostPath == nil {
+		return change.PrePath
+	}
+	return change.PostPath
+}
+
+// Pointer returns the Path of the change as a JSON Pointer (RFC 6901).
+func (change Change) Pointer() string {
+	return change.Path().String()
+}
+
+// A Visitor is called by Walk with each change of a Diff. The deltas nested
+// in the delta of the change are skipped when it returns false.
+type Visitor func(change Change) bool
+
+// Walk calls the visitor with the changes of the deltas of the Diff, depth
+// first in the order of the deltas, each container delta before its nested
+// deltas. The changes of a value moved by a Moved delta follow it, with the
+// same paths.
+func Walk(diff Diff, visitor Visitor) {
+	walkDeltas(Path{}, Path{}, diff.Deltas(), visitor)
+}
+
+// Changes returns the changes of all the deltas of the Diff but the Object
+// and Array deltas holding them, in the order of Walk.
+func (diff *diff) Changes() []Change {
+	changes := make([]Change, 0)
+	Walk(diff, func(change Change) bool {
+		switch change.Delta.(type) {
+		case *Object, *Array:
+		default:
+			changes = append(changes, change)
+		}
+		return true
+	})
+	return changes
+}
+
+// walkDeltas walks the deltas of the value at prePath in the left document
+// and at postPath in the right one.
+func walkDeltas(prePath Path, postPath Path, deltas []Delta, visitor Visitor) {
+	var indexes *arrayIndexMap
+	if isArrayDeltas(deltas) {
+		indexes = newArrayIndexMap(deltas)
+	}
+	for _, delta := range deltas {
+		change := changeOf(prePath, postPath, delta, indexes)
+		switch d := delta.(type) {
+		case *Added, *Deleted:
+			visitor(change)
+		case *Moved:
+			if nested, ok := d.Delta.(Delta); visitor(change) && ok {
+				walkDelta(change.PrePath, change.PostPath, nested, visitor)
+			}
+		default:
+			walkDelta(change.PrePath, change.PostPath, delta, visitor)
+		}
+	}
+}
+
+// changeOf returns the change of a delta of the value at prePath in the left
+// document and at postPath in the right one. indexes maps the indexes of the
+// items of the value, nil when it is not an array.
+func changeOf(prePath Path, postPath Path, delta Delta, indexes *arrayIndexMap) Change {
+	switch d := delta.(type) {
+	case *Added:
+		return Change{Delta: d, PostPath: pathAt(postPath, d.PostPosition())}
+	case *Deleted:
+		return Change{Delta: d, PrePath: pathAt(prePath, d.PrePosition())}
+	case *Moved:
+		return Change{Delta: d, PrePath: pathAt(prePath, d.PrePosition()), PostPath: pathAt(postPath, d.PostPosition())}
+	case PostDelta:
+		// kept items of arrays are changed at their post index
+		prePosition := d.PostPosition()
+		if index, ok := prePosition.(Index); ok && indexes != nil {
+			prePosition = Index(indexes.preIndex(int(index)))
+		}
+		return Change{Delta: delta, PrePath: pathAt(prePath, prePosition), PostPath: pathAt(postPath, d.PostPosition())}
+	}
+	return Change{Delta: delta}
+}
+
+// walkDelta walks a delta changing the value at the paths in place.
+func walkDelta(prePath Path, postPath Path, delta Delta, visitor Visitor) {
+	if !visitor(Change{Delta: delta, PrePath: prePath, PostPath: postPath}) {
+		return
+	}
+	switch d := delta.(type) {
+	case *Object:
+		walkDeltas(prePath, postPath, d.Deltas, visitor)
+	case *Array:
+		walkDeltas(prePath, postPath, d.Deltas, visitor)
+	}
+}
+
+// pathAt returns the path of the value at the position in the value at the
+// path, which is the path itself for the Root position.
+func pathAt(path Path, position Position) Path {
+	if isRoot(position) {
+		return append(Path{}, path...)
+	}
+	return path.child(position)
+}